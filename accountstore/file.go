@@ -0,0 +1,219 @@
+package accountstore
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/kangaroux/go-wow-srp6/authserver"
+	"github.com/kangaroux/go-wow-srp6/builds"
+)
+
+// File is a [Store] which keeps accounts in a JSON file. Accounts are kept in memory, and every
+// change rewrites the whole file, so it's meant for servers with a small number of accounts.
+//
+// Writes are atomic: the new file is written next to the old one and renamed over it, so a crash
+// never leaves a partially written file behind. File is safe to use concurrently, but the file
+// must not be modified by other processes while it's open.
+type File struct {
+	mem  Memory
+	path string
+}
+
+// fileAccount is the JSON encoding of an account.
+type fileAccount struct {
+	Username       string              `json:"username"`
+	Salt           string              `json:"salt"`
+	Verifier       string              `json:"verifier"`
+	SecurityFlags  builds.SecurityFlag `json:"security_flags,omitempty"`
+	Banned         bool                `json:"banned,omitempty"`
+	SuspendedUntil *time.Time          `json:"suspended_until,omitempty"`
+}
+
+type fileContents struct {
+	Accounts []fileAccount `json:"accounts"`
+}
+
+// OpenFile loads the accounts from the file at path. If the file doesn't exist, the store is
+// empty and the file is created on the first change.
+func OpenFile(path string) (*File, error) {
+	f := &File{
+		mem:  Memory{accounts: make(map[string]*authserver.Account)},
+		path: path,
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return f, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var contents fileContents
+	if err := json.Unmarshal(data, &contents); err != nil {
+		return nil, fmt.Errorf("srp/accountstore: %s: %w", path, err)
+	}
+
+	for _, fa := range contents.Accounts {
+		account, err := fa.account()
+		if err == nil {
+			err = create(f.mem.accounts, account)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("srp/accountstore: %s: account %q: %w", path, fa.Username, err)
+		}
+	}
+
+	return f, nil
+}
+
+// Path returns the path of the file.
+func (f *File) Path() string {
+	return f.path
+}
+
+func (f *File) Account(ctx context.Context, username string) (*authserver.Account, error) {
+	return f.mem.Account(ctx, username)
+}
+
+func (f *File) Create(ctx context.Context, account *authserver.Account) error {
+	return f.modify(func(accounts map[string]*authserver.Account) error {
+		return create(accounts, account)
+	})
+}
+
+func (f *File) Update(ctx context.Context, account *authserver.Account) error {
+	return f.modify(func(accounts map[string]*authserver.Account) error {
+		return update(accounts, account)
+	})
+}
+
+func (f *File) Delete(ctx context.Context, username string) error {
+	return f.modify(func(accounts map[string]*authserver.Account) error {
+		return remove(accounts, username)
+	})
+}
+
+// Accounts returns all accounts, sorted by username.
+func (f *File) Accounts() []*authserver.Account {
+	return f.mem.Accounts()
+}
+
+// modify applies fn to a copy of the accounts and saves the result. The accounts in memory are
+// only replaced once the file has been written.
+func (f *File) modify(fn func(accounts map[string]*authserver.Account) error) error {
+	return f.mem.modify(func(accounts map[string]*authserver.Account) error {
+		next := make(map[string]*authserver.Account, len(accounts)+1)
+		for k, v := range accounts {
+			next[k] = v
+		}
+
+		if err := fn(next); err != nil {
+			return err
+		}
+		if err := f.save(next); err != nil {
+			return err
+		}
+
+		f.mem.accounts = next
+		return nil
+	})
+}
+
+// save writes accounts to a temporary file and renames it over the store's file.
+func (f *File) save(accounts map[string]*authserver.Account) error {
+	contents := fileContents{Accounts: make([]fileAccount, 0, len(accounts))}
+	for _, a := range accounts {
+		contents.Accounts = append(contents.Accounts, newFileAccount(a))
+	}
+	sort.Slice(contents.Accounts, func(i, j int) bool {
+		return contents.Accounts[i].Username < contents.Accounts[j].Username
+	})
+
+	data, err := json.MarshalIndent(contents, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(f.path, append(data, '\n'))
+}
+
+// writeFileAtomic replaces the file at path with data. The file is only readable by its owner,
+// since verifiers can be used to brute force passwords. os.CreateTemp already uses mode 0600.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+
+	// Clean up the temporary file if anything fails. Once renamed this does nothing.
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// Sync the directory so the rename survives a crash
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+func newFileAccount(a *authserver.Account) fileAccount {
+	fa := fileAccount{
+		Username:      a.Username,
+		Salt:          hex.EncodeToString(a.Salt),
+		Verifier:      hex.EncodeToString(a.Verifier),
+		SecurityFlags: a.SecurityFlags,
+		Banned:        a.Banned,
+	}
+	if !a.SuspendedUntil.IsZero() {
+		t := a.SuspendedUntil.UTC()
+		fa.SuspendedUntil = &t
+	}
+	return fa
+}
+
+func (fa fileAccount) account() (*authserver.Account, error) {
+	salt, err := hex.DecodeString(fa.Salt)
+	if err != nil {
+		return nil, ErrInvalidAccount
+	}
+	verifier, err := hex.DecodeString(fa.Verifier)
+	if err != nil {
+		return nil, ErrInvalidAccount
+	}
+
+	a := &authserver.Account{
+		Username:      fa.Username,
+		Salt:          salt,
+		Verifier:      verifier,
+		SecurityFlags: fa.SecurityFlags,
+		Banned:        fa.Banned,
+	}
+	if fa.SuspendedUntil != nil {
+		a.SuspendedUntil = *fa.SuspendedUntil
+	}
+	return a, nil
+}
@@ -0,0 +1,134 @@
+package accountstore
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kangaroux/go-wow-srp6/builds"
+	"github.com/kangaroux/go-wow-srp6/seal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFile(t *testing.T) {
+	s, err := OpenFile(filepath.Join(t.TempDir(), "accounts.json"))
+	assert.NoError(t, err)
+	testStore(t, s)
+}
+
+func TestFileConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "accounts.json")
+	s, err := OpenFile(path)
+	assert.NoError(t, err)
+	testStoreConcurrent(t, s)
+
+	reopened, err := OpenFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, s.Accounts(), reopened.Accounts())
+}
+
+func TestFilePersists(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "accounts.json")
+
+	s, err := OpenFile(path)
+	assert.NoError(t, err)
+	assert.Empty(t, s.Accounts())
+
+	// The file isn't created until the first change
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	alice := mustNewAccount("alice", "password")
+	alice.SecurityFlags = builds.SecurityFlagPIN
+	alice.SuspendedUntil = time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.NoError(t, s.Create(ctx, alice))
+	assert.NoError(t, s.Create(ctx, mustNewAccount("bob", "password")))
+
+	reopened, err := OpenFile(path)
+	assert.NoError(t, err)
+	got, err := reopened.Account(ctx, "ALICE")
+	assert.NoError(t, err)
+	assert.Equal(t, alice, got)
+	assert.Equal(t, s.Accounts(), reopened.Accounts())
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// Only the accounts file is left in the directory
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestFileFailedWrite(t *testing.T) {
+	ctx := context.Background()
+
+	// The directory doesn't exist, so the file can't be written
+	s, err := OpenFile(filepath.Join(t.TempDir(), "missing", "accounts.json"))
+	assert.NoError(t, err)
+	assert.Error(t, s.Create(ctx, mustNewAccount("alice", "password")))
+
+	// The failed change isn't kept in memory
+	assert.Empty(t, s.Accounts())
+}
+
+func TestOpenFileInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+	}{
+		{"not json", "accounts"},
+		{"bad hex", `{"accounts": [{"username": "alice", "salt": "zz", "verifier": "00"}]}`},
+		{"bad size", `{"accounts": [{"username": "alice", "salt": "00", "verifier": "00"}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "accounts.json")
+			assert.NoError(t, os.WriteFile(path, []byte(tt.contents), 0o600))
+
+			_, err := OpenFile(path)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestFileSealedVerifiers(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "accounts.json")
+
+	keys, err := seal.NewKeyring(1, bytes.Repeat([]byte{1}, seal.KeySize))
+	assert.NoError(t, err)
+
+	account, err := NewAccount("alice", "password")
+	assert.NoError(t, err)
+	verifier := account.Verifier
+
+	account.Verifier, err = keys.SealVerifier(account.Username, verifier)
+	assert.NoError(t, err)
+
+	s, err := OpenFile(path)
+	assert.NoError(t, err)
+	assert.NoError(t, s.Create(ctx, account))
+
+	// Only the sealed verifier is saved, and the wrapper opens it after reopening the file
+	reopened, err := OpenFile(path)
+	assert.NoError(t, err)
+	stored, err := reopened.Account(ctx, "ALICE")
+	assert.NoError(t, err)
+	assert.Equal(t, account.Verifier, stored.Verifier)
+
+	opened, err := (&seal.AccountStore{Store: reopened, Keys: keys}).Account(ctx, "ALICE")
+	assert.NoError(t, err)
+	assert.Equal(t, verifier, opened.Verifier)
+
+	// A verifier that's neither plain nor sealed is still rejected
+	account.Verifier = account.Verifier[1:]
+	assert.ErrorIs(t, reopened.Update(ctx, account), ErrInvalidAccount)
+}
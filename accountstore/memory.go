@@ -0,0 +1,105 @@
+package accountstore
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kangaroux/go-wow-srp6/authserver"
+)
+
+// Memory is a [Store] which keeps accounts in memory. It is safe to use concurrently.
+type Memory struct {
+	mu       sync.RWMutex
+	accounts map[string]*authserver.Account
+}
+
+// NewMemory returns an empty in-memory store.
+func NewMemory() *Memory {
+	return &Memory{accounts: make(map[string]*authserver.Account)}
+}
+
+func (m *Memory) Account(ctx context.Context, username string) (*authserver.Account, error) {
+	m.mu.RLock()
+	account, ok := m.accounts[strings.ToUpper(username)]
+	m.mu.RUnlock()
+
+	if !ok {
+		return nil, authserver.ErrAccountNotFound
+	}
+	return clone(account), nil
+}
+
+func (m *Memory) Create(ctx context.Context, account *authserver.Account) error {
+	return m.modify(func(accounts map[string]*authserver.Account) error {
+		return create(accounts, account)
+	})
+}
+
+func (m *Memory) Update(ctx context.Context, account *authserver.Account) error {
+	return m.modify(func(accounts map[string]*authserver.Account) error {
+		return update(accounts, account)
+	})
+}
+
+func (m *Memory) Delete(ctx context.Context, username string) error {
+	return m.modify(func(accounts map[string]*authserver.Account) error {
+		return remove(accounts, username)
+	})
+}
+
+// Accounts returns all accounts, sorted by username.
+func (m *Memory) Accounts() []*authserver.Account {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	accounts := make([]*authserver.Account, 0, len(m.accounts))
+	for _, a := range m.accounts {
+		accounts = append(accounts, clone(a))
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].Username < accounts[j].Username
+	})
+	return accounts
+}
+
+// modify runs fn with the write lock held.
+func (m *Memory) modify(fn func(accounts map[string]*authserver.Account) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return fn(m.accounts)
+}
+
+func create(accounts map[string]*authserver.Account, account *authserver.Account) error {
+	a, err := normalize(account)
+	if err != nil {
+		return err
+	}
+	if _, ok := accounts[a.Username]; ok {
+		return ErrAccountExists
+	}
+	accounts[a.Username] = a
+	return nil
+}
+
+func update(accounts map[string]*authserver.Account, account *authserver.Account) error {
+	a, err := normalize(account)
+	if err != nil {
+		return err
+	}
+	if _, ok := accounts[a.Username]; !ok {
+		return authserver.ErrAccountNotFound
+	}
+	accounts[a.Username] = a
+	return nil
+}
+
+func remove(accounts map[string]*authserver.Account, username string) error {
+	username = strings.ToUpper(username)
+	if _, ok := accounts[username]; !ok {
+		return authserver.ErrAccountNotFound
+	}
+	delete(accounts, username)
+	return nil
+}
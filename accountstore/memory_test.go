@@ -0,0 +1,89 @@
+package accountstore
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/kangaroux/go-wow-srp6/authserver"
+	"github.com/stretchr/testify/assert"
+)
+
+func mustNewAccount(username, password string) *authserver.Account {
+	account, err := NewAccount(username, password)
+	if err != nil {
+		panic(err)
+	}
+	return account
+}
+
+// testStore runs the tests shared by all stores.
+func testStore(t *testing.T, s Store) {
+	ctx := context.Background()
+	alice := mustNewAccount("alice", "password")
+
+	_, err := s.Account(ctx, "ALICE")
+	assert.ErrorIs(t, err, authserver.ErrAccountNotFound)
+
+	assert.NoError(t, s.Create(ctx, alice))
+	assert.ErrorIs(t, s.Create(ctx, alice), ErrAccountExists)
+
+	got, err := s.Account(ctx, "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, alice, got)
+
+	// The stored account is a copy
+	got.Banned = true
+	got.Salt[0]++
+	got, _ = s.Account(ctx, "ALICE")
+	assert.Equal(t, alice, got)
+
+	alice.Banned = true
+	assert.NoError(t, s.Update(ctx, alice))
+	got, _ = s.Account(ctx, "ALICE")
+	assert.True(t, got.Banned)
+
+	assert.ErrorIs(t, s.Update(ctx, mustNewAccount("bob", "password")), authserver.ErrAccountNotFound)
+	assert.ErrorIs(t, s.Create(ctx, &authserver.Account{}), ErrInvalidUsername)
+	assert.ErrorIs(t, s.Create(ctx, &authserver.Account{Username: "bob"}), ErrInvalidAccount)
+
+	assert.NoError(t, s.Delete(ctx, "Alice"))
+	assert.ErrorIs(t, s.Delete(ctx, "ALICE"), authserver.ErrAccountNotFound)
+	_, err = s.Account(ctx, "ALICE")
+	assert.ErrorIs(t, err, authserver.ErrAccountNotFound)
+}
+
+// testStoreConcurrent creates and looks up accounts from several goroutines. Run with -race.
+func testStoreConcurrent(t *testing.T, s Store) {
+	ctx := context.Background()
+	var wg sync.WaitGroup
+
+	for i := 0; i < 8; i++ {
+		account := mustNewAccount(fmt.Sprintf("user%d", i), "password")
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, s.Create(ctx, account))
+			for j := 0; j < 10; j++ {
+				got, err := s.Account(ctx, account.Username)
+				assert.NoError(t, err)
+				assert.Equal(t, account, got)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func TestMemory(t *testing.T) {
+	testStore(t, NewMemory())
+}
+
+func TestMemoryConcurrent(t *testing.T) {
+	s := NewMemory()
+	testStoreConcurrent(t, s)
+	assert.Len(t, s.Accounts(), 8)
+	assert.Equal(t, "USER0", s.Accounts()[0].Username)
+}
@@ -0,0 +1,81 @@
+// Package accountstore provides [authserver.AccountStore] implementations which don't need a
+// database: an in-memory store for tests, and a JSON file store for small servers.
+//
+// Usernames are normalized to uppercase, which is how the client sends them. Verifiers can be
+// stored sealed with [seal.Keyring.SealVerifier], in which case the store should be wrapped with
+// [seal.AccountStore] to open them.
+package accountstore
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"strings"
+
+	srp "github.com/kangaroux/go-wow-srp6"
+	"github.com/kangaroux/go-wow-srp6/authserver"
+	"github.com/kangaroux/go-wow-srp6/seal"
+)
+
+var (
+	ErrAccountExists   = errors.New("srp/accountstore: account already exists")
+	ErrInvalidUsername = errors.New("srp/accountstore: invalid username")
+	ErrInvalidAccount  = errors.New("srp/accountstore: invalid account")
+)
+
+// Store is an [authserver.AccountStore] which can also add, update and remove accounts.
+// Implementations must be safe to use concurrently.
+type Store interface {
+	authserver.AccountStore
+
+	// Create adds a new account. If the account already exists, Create returns ErrAccountExists.
+	Create(ctx context.Context, account *authserver.Account) error
+
+	// Update replaces an existing account. If the account does not exist, Update returns
+	// [authserver.ErrAccountNotFound].
+	Update(ctx context.Context, account *authserver.Account) error
+
+	// Delete removes an account. If the account does not exist, Delete returns
+	// [authserver.ErrAccountNotFound].
+	Delete(ctx context.Context, username string) error
+}
+
+// NewAccount returns an account with a random salt and the verifier for password.
+func NewAccount(username, password string) (*authserver.Account, error) {
+	salt := make([]byte, srp.SaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	username = strings.ToUpper(username)
+	return &authserver.Account{
+		Username: username,
+		Salt:     salt,
+		Verifier: srp.PasswordVerifier(username, strings.ToUpper(password), salt),
+	}, nil
+}
+
+// normalize checks the account and returns a copy of it with a normalized username.
+func normalize(account *authserver.Account) (*authserver.Account, error) {
+	if account.Username == "" {
+		return nil, ErrInvalidUsername
+	}
+	if len(account.Salt) != srp.SaltSize {
+		return nil, ErrInvalidAccount
+	}
+	if len(account.Verifier) != srp.VerifierSize && len(account.Verifier) != seal.SealedVerifierSize {
+		return nil, ErrInvalidAccount
+	}
+
+	a := clone(account)
+	a.Username = strings.ToUpper(a.Username)
+	return a, nil
+}
+
+// clone returns a deep copy of account, so callers can't modify the stored account.
+func clone(account *authserver.Account) *authserver.Account {
+	a := *account
+	a.Salt = append([]byte(nil), account.Salt...)
+	a.Verifier = append([]byte(nil), account.Verifier...)
+	return &a
+}
@@ -0,0 +1,20 @@
+package accountstore
+
+import (
+	"testing"
+
+	srp "github.com/kangaroux/go-wow-srp6"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAccount(t *testing.T) {
+	account, err := NewAccount("alice", "password123")
+	assert.NoError(t, err)
+	assert.Equal(t, "ALICE", account.Username)
+	assert.Len(t, account.Salt, srp.SaltSize)
+	assert.Equal(t, srp.PasswordVerifier("ALICE", "PASSWORD123", account.Salt), account.Verifier)
+
+	other, err := NewAccount("alice", "password123")
+	assert.NoError(t, err)
+	assert.NotEqual(t, account.Salt, other.Salt)
+}
@@ -0,0 +1,337 @@
+// Package authclient is a minimal auth (logon) client, mainly used for integration testing auth
+// servers built on this library.
+//
+// The client logs in with a username and password, verifies the server's proof, can reconnect
+// using a previous session key, and fetches the realm list.
+package authclient
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	srp "github.com/kangaroux/go-wow-srp6"
+	"github.com/kangaroux/go-wow-srp6/authproto"
+	"github.com/kangaroux/go-wow-srp6/builds"
+)
+
+// DefaultBuild is the build the client identifies as if [Client.Build] is not set (3.3.5a).
+const DefaultBuild = 12340
+
+var (
+	ErrInvalidServerProof = errors.New("srp/authclient: server proof does not match")
+	ErrUnsupportedParams  = errors.New("srp/authclient: server sent an unsupported generator or prime")
+	ErrSecurityFlags      = errors.New("srp/authclient: server requires security flags, which are not supported")
+	ErrNotLoggedIn        = errors.New("srp/authclient: client has not logged in")
+)
+
+// LoginError is returned when the server rejects the login.
+type LoginError struct {
+	Result authproto.LoginResult
+}
+
+func (e *LoginError) Error() string {
+	return "srp/authclient: server rejected login: " + e.Result.String()
+}
+
+// LoginResult returns the result sent by the server.
+func (e *LoginError) LoginResult() authproto.LoginResult {
+	return e.Result
+}
+
+// Client is a connection to an auth server. A Client is not safe to use concurrently.
+type Client struct {
+	Username string
+	Password string
+
+	// Build is the client build to identify as. Defaults to [DefaultBuild].
+	Build uint16
+
+	// Rand is the source of the client's private key and proof data. Defaults to
+	// [crypto/rand.Reader].
+	Rand io.Reader
+
+	conn net.Conn
+	r    *bufio.Reader
+
+	build         builds.Build
+	sessionKey    []byte
+	authenticated bool
+}
+
+// Dial connects to the auth server at addr.
+func Dial(ctx context.Context, addr, username, password string) (*Client, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return New(conn, username, password), nil
+}
+
+// New returns a client which uses conn to talk to the auth server.
+func New(conn net.Conn, username, password string) *Client {
+	return &Client{
+		Username: username,
+		Password: password,
+		conn:     conn,
+		r:        bufio.NewReader(conn),
+	}
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// SessionKey returns the session key from the last successful login. It returns nil until the
+// client has logged in.
+func (c *Client) SessionKey() []byte {
+	return c.sessionKey
+}
+
+// Login performs the logon challenge and proof, and checks the server's proof. On success, the
+// session key is returned. If the server rejects the login, Login returns a [*LoginError].
+func (c *Client) Login(ctx context.Context) ([]byte, error) {
+	err := c.withContext(ctx, func() error {
+		if err := c.send(c.challenge()); err != nil {
+			return err
+		}
+
+		reply := &authproto.LogonChallengeReply{ProtocolVersion: c.build.ProtocolVersion}
+		if err := reply.Decode(c.r); err != nil {
+			return err
+		}
+		if reply.Result != authproto.LoginSuccess {
+			return &LoginError{Result: reply.Result}
+		}
+		if reply.Generator != srp.Generator || subtle.ConstantTimeCompare(reply.LargePrime, srp.LargePrime()) != 1 {
+			return ErrUnsupportedParams
+		}
+		if reply.SecurityFlags != builds.SecurityFlagNone {
+			return ErrSecurityFlags
+		}
+
+		return c.proof(reply)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return c.sessionKey, nil
+}
+
+// proof sends the logon proof in response to reply and checks the server's proof.
+func (c *Client) proof(reply *authproto.LogonChallengeReply) error {
+	clientPrivateKey, err := c.randomBytes(srp.KeySize)
+	if err != nil {
+		return err
+	}
+
+	// The game client uppercases the username and password before computing the keys
+	username := strings.ToUpper(c.Username)
+	password := strings.ToUpper(c.Password)
+
+	clientPublicKey := srp.ClientPublicKey(clientPrivateKey)
+	sessionKey := srp.ClientSessionKey(
+		username,
+		password,
+		reply.Salt,
+		clientPublicKey,
+		reply.ServerPublicKey,
+		clientPrivateKey,
+	)
+	clientProof := srp.ClientChallengeProof(username, reply.Salt, clientPublicKey, reply.ServerPublicKey, sessionKey)
+
+	err = c.send(&authproto.LogonProof{
+		ProtocolVersion: c.build.ProtocolVersion,
+		ClientPublicKey: clientPublicKey,
+		ClientProof:     clientProof,
+		CRCHash:         make([]byte, authproto.CRCHashSize),
+	})
+	if err != nil {
+		return err
+	}
+
+	proofReply := &authproto.LogonProofReply{ProtocolVersion: c.build.ProtocolVersion}
+	if err := proofReply.Decode(c.r); err != nil {
+		return err
+	}
+	if proofReply.Result != authproto.LoginSuccess {
+		return &LoginError{Result: proofReply.Result}
+	}
+
+	expected := srp.ServerChallengeProof(clientPublicKey, clientProof, sessionKey)
+	if subtle.ConstantTimeCompare(expected, proofReply.ServerProof) != 1 {
+		return ErrInvalidServerProof
+	}
+
+	c.sessionKey = sessionKey
+	c.authenticated = true
+	return nil
+}
+
+// Reconnect performs the reconnect challenge and proof using the session key from a previous
+// login. If the server rejects the reconnect, Reconnect returns a [*LoginError].
+func (c *Client) Reconnect(ctx context.Context, sessionKey []byte) error {
+	return c.withContext(ctx, func() error {
+		if err := c.send((*authproto.ReconnectChallenge)(c.challenge())); err != nil {
+			return err
+		}
+
+		reply := &authproto.ReconnectChallengeReply{ProtocolVersion: c.build.ProtocolVersion}
+		if err := reply.Decode(c.r); err != nil {
+			return err
+		}
+		if reply.Result != authproto.LoginSuccess {
+			return &LoginError{Result: reply.Result}
+		}
+
+		clientData, err := c.randomBytes(srp.ProofDataSize)
+		if err != nil {
+			return err
+		}
+
+		err = c.send(&authproto.ReconnectProof{
+			ProofData:      clientData,
+			ClientProof:    srp.ReconnectProof(strings.ToUpper(c.Username), clientData, reply.ChallengeData, sessionKey),
+			ClientChecksum: make([]byte, authproto.ClientChecksumSize),
+		})
+		if err != nil {
+			return err
+		}
+
+		proofReply := &authproto.ReconnectProofReply{ProtocolVersion: c.build.ProtocolVersion}
+		if err := proofReply.Decode(c.r); err != nil {
+			return err
+		}
+		if proofReply.Result != authproto.LoginSuccess {
+			return &LoginError{Result: proofReply.Result}
+		}
+
+		c.sessionKey = sessionKey
+		c.authenticated = true
+		return nil
+	})
+}
+
+// RealmList requests the realm list. The client must have logged in or reconnected first.
+func (c *Client) RealmList(ctx context.Context) ([]authproto.Realm, error) {
+	if !c.authenticated {
+		return nil, ErrNotLoggedIn
+	}
+
+	var realms []authproto.Realm
+	err := c.withContext(ctx, func() error {
+		if err := c.send(&authproto.RealmListRequest{}); err != nil {
+			return err
+		}
+
+		list := &authproto.RealmList{ProtocolVersion: c.build.ProtocolVersion}
+		if err := list.Decode(c.r); err != nil {
+			return err
+		}
+		realms = list.Realms
+		return nil
+	})
+
+	return realms, err
+}
+
+// challenge returns the challenge packet sent by the client to log in or reconnect.
+func (c *Client) challenge() *authproto.LogonChallenge {
+	number := c.Build
+	if number == 0 {
+		number = DefaultBuild
+	}
+
+	// Unknown builds are still sent, this lets the client test how servers handle them
+	build, ok := builds.Lookup(number)
+	if !ok {
+		build = builds.Build{Number: number, ProtocolVersion: builds.ProtocolVersionWrath}
+	}
+	c.build = build
+
+	return &authproto.LogonChallenge{
+		ProtocolVersion: build.ProtocolVersion,
+		GameName:        "WoW",
+		Major:           build.Major,
+		Minor:           build.Minor,
+		Patch:           build.Patch,
+		Build:           build.Number,
+		Platform:        "x86",
+		OS:              "Win",
+		Locale:          "enUS",
+		Username:        strings.ToUpper(c.Username),
+	}
+}
+
+func (c *Client) send(p interface{ MarshalBinary() ([]byte, error) }) error {
+	data, err := p.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = c.conn.Write(data)
+	return err
+}
+
+// withContext runs fn, interrupting any blocked reads and writes once ctx is done. If fn was
+// interrupted, withContext returns ctx.Err().
+func (c *Client) withContext(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// The deadline is always cleared afterwards, since a canceled context sets one in the past
+	deadline, _ := ctx.Deadline()
+	c.conn.SetDeadline(deadline)
+	defer c.conn.SetDeadline(time.Time{})
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		select {
+		case <-ctx.Done():
+			c.conn.SetDeadline(time.Unix(1, 0))
+		case <-stop:
+		}
+	}()
+
+	err := fn()
+	close(stop)
+	<-done
+
+	if err == nil {
+		return nil
+	}
+
+	// The connection can reach the context's deadline before ctx.Err is set
+	if errors.Is(err, os.ErrDeadlineExceeded) && !deadline.IsZero() && !time.Now().Before(deadline) {
+		<-ctx.Done()
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func (c *Client) randomBytes(n int) ([]byte, error) {
+	r := c.Rand
+	if r == nil {
+		r = rand.Reader
+	}
+
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
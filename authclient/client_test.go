@@ -0,0 +1,246 @@
+package authclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	srp "github.com/kangaroux/go-wow-srp6"
+	"github.com/kangaroux/go-wow-srp6/authproto"
+	"github.com/kangaroux/go-wow-srp6/authserver"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testUsername = "alice"
+	testPassword = "password123"
+)
+
+type testAccounts map[string]*authserver.Account
+
+func (a testAccounts) Account(ctx context.Context, username string) (*authserver.Account, error) {
+	if account, ok := a[username]; ok {
+		return account, nil
+	}
+	return nil, authserver.ErrAccountNotFound
+}
+
+func newTestServer() *authserver.Server {
+	salt := bytes.Repeat([]byte{0x5A}, srp.SaltSize)
+	return &authserver.Server{
+		Accounts: testAccounts{
+			"ALICE": {
+				Username: "ALICE",
+				Salt:     salt,
+				Verifier: srp.PasswordVerifier("ALICE", "PASSWORD123", salt),
+			},
+		},
+		Sessions: authserver.NewMemorySessionKeyStore(),
+		Realms: func(ctx context.Context, username string) ([]authproto.Realm, error) {
+			return []authproto.Realm{{Name: "Test", Address: "localhost:8085", CharacterCount: 1}}, nil
+		},
+	}
+}
+
+// connect returns a client connected to s over a pipe, and a channel with the result of
+// ServeConn.
+func connect(s *authserver.Server, username, password string) (*Client, <-chan error) {
+	serverConn, clientConn := net.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- s.ServeConn(context.Background(), serverConn)
+	}()
+	return New(clientConn, username, password), done
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestLogin(t *testing.T) {
+	s := newTestServer()
+	c, done := connect(s, testUsername, testPassword)
+	ctx := testContext(t)
+
+	sessionKey, err := c.Login(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, sessionKey, srp.SessionKeySize)
+
+	saved, err := s.Sessions.SessionKey(ctx, "ALICE")
+	assert.NoError(t, err)
+	assert.Equal(t, saved, sessionKey)
+
+	realms, err := c.RealmList(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []authproto.Realm{{Name: "Test", Address: "localhost:8085", CharacterCount: 1}}, realms)
+
+	c.Close()
+	assert.NoError(t, <-done)
+}
+
+func TestLoginBuilds(t *testing.T) {
+	for _, build := range []uint16{5875, 8606, 12340} {
+		c, done := connect(newTestServer(), testUsername, testPassword)
+		c.Build = build
+
+		_, err := c.Login(testContext(t))
+		assert.NoError(t, err, build)
+
+		_, err = c.RealmList(testContext(t))
+		assert.NoError(t, err, build)
+
+		c.Close()
+		assert.NoError(t, <-done, build)
+	}
+}
+
+func TestLoginFailed(t *testing.T) {
+	tests := []struct {
+		name     string
+		username string
+		password string
+		build    uint16
+		expected authproto.LoginResult
+	}{
+		{"incorrect password", testUsername, "wrong", 0, authproto.LoginFailIncorrectPassword},
+		{"unknown account", "bob", testPassword, 0, authproto.LoginFailUnknownAccount},
+		{"unsupported build", testUsername, testPassword, 1234, authproto.LoginFailVersionInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, done := connect(newTestServer(), tt.username, tt.password)
+			c.Build = tt.build
+			defer c.Close()
+
+			_, err := c.Login(testContext(t))
+			var loginErr *LoginError
+			assert.True(t, errors.As(err, &loginErr))
+			assert.Equal(t, tt.expected, loginErr.LoginResult())
+
+			var serverErr *authserver.LoginError
+			assert.True(t, errors.As(<-done, &serverErr))
+			assert.Equal(t, tt.expected, serverErr.Result)
+
+			_, err = c.RealmList(testContext(t))
+			assert.ErrorIs(t, err, ErrNotLoggedIn)
+		})
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	s := newTestServer()
+
+	c, done := connect(s, testUsername, testPassword)
+	sessionKey, err := c.Login(testContext(t))
+	assert.NoError(t, err)
+	c.Close()
+	assert.NoError(t, <-done)
+
+	c, done = connect(s, testUsername, testPassword)
+	assert.NoError(t, c.Reconnect(testContext(t), sessionKey))
+	assert.Equal(t, sessionKey, c.SessionKey())
+
+	_, err = c.RealmList(testContext(t))
+	assert.NoError(t, err)
+	c.Close()
+	assert.NoError(t, <-done)
+
+	// Reconnecting with the wrong session key is rejected
+	c, done = connect(s, testUsername, testPassword)
+	err = c.Reconnect(testContext(t), make([]byte, srp.SessionKeySize))
+	var loginErr *LoginError
+	assert.True(t, errors.As(err, &loginErr))
+	assert.Equal(t, authproto.LoginFailIncorrectPassword, loginErr.Result)
+	c.Close()
+	assert.Error(t, <-done)
+}
+
+func TestLoginContextCanceled(t *testing.T) {
+	// Nothing serves the other end of the pipe, so the client blocks until the context is done
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+
+	c := New(clientConn, testUsername, testPassword)
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err := c.Login(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+// lateContext is a context whose deadline has passed before it's done, like a context from
+// context.WithDeadline whose timer hasn't fired yet.
+type lateContext struct {
+	context.Context
+	deadline time.Time
+	done     chan struct{}
+}
+
+// newLateContext returns a context with a deadline of d, which is only done after late.
+func newLateContext(d, late time.Duration) *lateContext {
+	ctx := &lateContext{
+		Context:  context.Background(),
+		deadline: time.Now().Add(d),
+		done:     make(chan struct{}),
+	}
+	time.AfterFunc(late, func() { close(ctx.done) })
+	return ctx
+}
+
+func (c *lateContext) Deadline() (time.Time, bool) { return c.deadline, true }
+func (c *lateContext) Done() <-chan struct{}       { return c.done }
+
+func (c *lateContext) Err() error {
+	select {
+	case <-c.done:
+		return context.DeadlineExceeded
+	default:
+		return nil
+	}
+}
+
+func TestLoginContextDeadline(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+
+	c := New(clientConn, testUsername, testPassword)
+	defer c.Close()
+
+	// The connection times out before the context is done
+	_, err := c.Login(newLateContext(10*time.Millisecond, 50*time.Millisecond))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestLoginAfterContextCanceled(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	c := New(clientConn, testUsername, testPassword)
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	_, err := c.Login(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// Nothing was sent, so the client can log in once the server is there
+	done := make(chan error, 1)
+	go func() {
+		done <- newTestServer().ServeConn(context.Background(), serverConn)
+	}()
+
+	// The deadline which interrupted the first login is gone
+	_, err = c.Login(context.Background())
+	assert.NoError(t, err)
+	c.Close()
+	<-done
+}
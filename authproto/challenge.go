@@ -0,0 +1,265 @@
+package authproto
+
+import (
+	"io"
+
+	srp "github.com/kangaroux/go-wow-srp6"
+	"github.com/kangaroux/go-wow-srp6/builds"
+)
+
+const (
+	VersionChallengeSize = 16
+	PINSaltSize          = 16
+
+	// The game name is a null padded string, which unlike the platform, OS and locale is not
+	// reversed.
+	gameNameSize = 4
+
+	// challengeFixedSize is the size of the logon challenge after the size field, excluding
+	// the username.
+	challengeFixedSize = 30
+)
+
+// LogonChallenge is sent by the client to begin logging in (CMD_AUTH_LOGON_CHALLENGE).
+type LogonChallenge struct {
+	ProtocolVersion uint8
+
+	// GameName is "WoW" for all retail clients. It is at most 4 bytes.
+	GameName string
+
+	Major uint8
+	Minor uint8
+	Patch uint8
+	Build uint16
+
+	// Platform, OS and Locale are short strings such as "x86", "Win" and "enUS".
+	Platform string
+	OS       string
+	Locale   string
+
+	// TimezoneBias is the client's offset from UTC in minutes.
+	TimezoneBias uint32
+
+	// ClientIP is the client's local IPv4 address.
+	ClientIP [4]byte
+
+	Username string
+}
+
+// MarshalBinary returns the wire format of the challenge, including the opcode.
+func (p *LogonChallenge) MarshalBinary() ([]byte, error) {
+	return p.marshal(OpcodeLogonChallenge)
+}
+
+// UnmarshalBinary parses a complete challenge packet, including the opcode.
+func (p *LogonChallenge) UnmarshalBinary(data []byte) error {
+	return unmarshal(data, p.Decode)
+}
+
+// Decode reads a single challenge packet from r, including the opcode.
+func (p *LogonChallenge) Decode(r io.Reader) error {
+	return p.decode(r, OpcodeLogonChallenge)
+}
+
+func (p *LogonChallenge) marshal(opcode uint8) ([]byte, error) {
+	if len(p.Username) > 0xFF {
+		return nil, ErrStringTooLong
+	}
+
+	e := &encoder{}
+	e.u8(opcode)
+	e.u8(p.ProtocolVersion)
+	e.u16(uint16(challengeFixedSize + len(p.Username)))
+	e.paddedString(gameNameSize, p.GameName)
+	e.u8(p.Major)
+	e.u8(p.Minor)
+	e.u8(p.Patch)
+	e.u16(p.Build)
+	e.fourCC(p.Platform)
+	e.fourCC(p.OS)
+	e.fourCC(p.Locale)
+	e.u32(p.TimezoneBias)
+	e.fixed(4, p.ClientIP[:])
+	e.string(p.Username)
+	return e.bytes()
+}
+
+func (p *LogonChallenge) decode(r io.Reader, opcode uint8) error {
+	d := &decoder{r: r}
+	d.opcode(opcode)
+	p.ProtocolVersion = d.u8()
+	size := d.u16()
+	p.GameName = d.paddedString(gameNameSize)
+	p.Major = d.u8()
+	p.Minor = d.u8()
+	p.Patch = d.u8()
+	p.Build = d.u16()
+	p.Platform = d.fourCC()
+	p.OS = d.fourCC()
+	p.Locale = d.fourCC()
+	p.TimezoneBias = d.u32()
+	d.array(p.ClientIP[:])
+
+	// Check the size before reading the username so a bogus size can't be used to make us wait
+	// for data that will never arrive.
+	usernameLen := d.u8()
+	if d.err == nil && int(size) != challengeFixedSize+int(usernameLen) {
+		return ErrInvalidSize
+	}
+	p.Username = string(d.bytes(int(usernameLen)))
+	return d.err
+}
+
+// LogonChallengeReply is the server's reply to [LogonChallenge] (CMD_AUTH_LOGON_CHALLENGE).
+// If Result is not [LoginSuccess], only the result is sent.
+type LogonChallengeReply struct {
+	// ProtocolVersion is the protocol version sent by the client. It is not sent, but determines
+	// whether the security flags are included.
+	ProtocolVersion uint8
+
+	Result LoginResult
+
+	ServerPublicKey []byte
+	Generator       uint8
+	LargePrime      []byte
+	Salt            []byte
+
+	// VersionChallenge is a random value used by the client to prove its files are unmodified.
+	VersionChallenge []byte
+
+	// SecurityFlags is only sent for protocol version 3 and later.
+	SecurityFlags builds.SecurityFlag
+
+	PINGridSeed uint32
+	PINSalt     []byte
+
+	MatrixWidth          uint8
+	MatrixHeight         uint8
+	MatrixDigitCount     uint8
+	MatrixChallengeCount uint8
+	MatrixSeed           uint64
+
+	AuthenticatorRequired bool
+}
+
+// NewLogonChallengeReply returns a successful reply using the generator and large prime from the
+// srp package.
+func NewLogonChallengeReply(protocolVersion uint8, serverPublicKey, salt, versionChallenge []byte) *LogonChallengeReply {
+	return &LogonChallengeReply{
+		ProtocolVersion:  protocolVersion,
+		Result:           LoginSuccess,
+		ServerPublicKey:  serverPublicKey,
+		Generator:        srp.Generator,
+		LargePrime:       srp.LargePrime(),
+		Salt:             salt,
+		VersionChallenge: versionChallenge,
+	}
+}
+
+// MarshalBinary returns the wire format of the reply, including the opcode.
+func (p *LogonChallengeReply) MarshalBinary() ([]byte, error) {
+	e := &encoder{}
+	e.u8(OpcodeLogonChallenge)
+	e.u8(0)
+	e.u8(p.Result.Wire(p.ProtocolVersion))
+
+	if p.Result != LoginSuccess {
+		return e.bytes()
+	}
+
+	e.fixed(srp.KeySize, p.ServerPublicKey)
+	e.u8(1)
+	e.u8(p.Generator)
+	e.u8(srp.LargePrimeSize)
+	e.fixed(srp.LargePrimeSize, p.LargePrime)
+	e.fixed(srp.SaltSize, p.Salt)
+	e.fixed(VersionChallengeSize, p.VersionChallenge)
+
+	if p.ProtocolVersion < 3 {
+		return e.bytes()
+	}
+
+	e.u8(uint8(p.SecurityFlags))
+
+	if p.SecurityFlags&builds.SecurityFlagPIN != 0 {
+		e.u32(p.PINGridSeed)
+		e.fixed(PINSaltSize, p.PINSalt)
+	}
+
+	if p.SecurityFlags&builds.SecurityFlagMatrixCard != 0 {
+		e.u8(p.MatrixWidth)
+		e.u8(p.MatrixHeight)
+		e.u8(p.MatrixDigitCount)
+		e.u8(p.MatrixChallengeCount)
+		e.u64(p.MatrixSeed)
+	}
+
+	if p.SecurityFlags&builds.SecurityFlagAuthenticator != 0 {
+		e.u8(boolToByte(p.AuthenticatorRequired))
+	}
+
+	return e.bytes()
+}
+
+// UnmarshalBinary parses a complete reply, including the opcode. ProtocolVersion must be set
+// before calling UnmarshalBinary.
+func (p *LogonChallengeReply) UnmarshalBinary(data []byte) error {
+	return unmarshal(data, p.Decode)
+}
+
+// Decode reads a single reply from r, including the opcode. ProtocolVersion must be set
+// before calling Decode.
+func (p *LogonChallengeReply) Decode(r io.Reader) error {
+	d := &decoder{r: r}
+	d.opcode(OpcodeLogonChallenge)
+	d.u8()
+	p.Result = LoginResult(d.u8())
+
+	if d.err != nil || p.Result != LoginSuccess {
+		return d.err
+	}
+
+	p.ServerPublicKey = d.bytes(srp.KeySize)
+	if n := d.u8(); d.err == nil && n != 1 {
+		return ErrInvalidField
+	}
+	p.Generator = d.u8()
+	if n := d.u8(); d.err == nil && n != srp.LargePrimeSize {
+		return ErrInvalidField
+	}
+	p.LargePrime = d.bytes(srp.LargePrimeSize)
+	p.Salt = d.bytes(srp.SaltSize)
+	p.VersionChallenge = d.bytes(VersionChallengeSize)
+
+	if p.ProtocolVersion < 3 {
+		return d.err
+	}
+
+	p.SecurityFlags = builds.SecurityFlag(d.u8())
+
+	if p.SecurityFlags&builds.SecurityFlagPIN != 0 {
+		p.PINGridSeed = d.u32()
+		p.PINSalt = d.bytes(PINSaltSize)
+	}
+
+	if p.SecurityFlags&builds.SecurityFlagMatrixCard != 0 {
+		p.MatrixWidth = d.u8()
+		p.MatrixHeight = d.u8()
+		p.MatrixDigitCount = d.u8()
+		p.MatrixChallengeCount = d.u8()
+		p.MatrixSeed = d.u64()
+	}
+
+	if p.SecurityFlags&builds.SecurityFlagAuthenticator != 0 {
+		p.AuthenticatorRequired = d.u8() != 0
+	}
+
+	return d.err
+}
+
+func boolToByte(b bool) uint8 {
+	if b {
+		return 1
+	}
+	return 0
+}
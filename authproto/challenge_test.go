@@ -0,0 +1,198 @@
+package authproto
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	srp "github.com/kangaroux/go-wow-srp6"
+	"github.com/kangaroux/go-wow-srp6/builds"
+	"github.com/kangaroux/go-wow-srp6/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+// A 1.12.1 client logging in as "A" from 127.0.0.1.
+var vanillaChallenge = internal.MustDecodeHex(
+	"00031F00576F5700010C01F316363878006E69570053556E653C0000007F0000010141",
+)
+
+func TestLogonChallenge(t *testing.T) {
+	expected := LogonChallenge{
+		ProtocolVersion: 3,
+		GameName:        "WoW",
+		Major:           1,
+		Minor:           12,
+		Patch:           1,
+		Build:           5875,
+		Platform:        "x86",
+		OS:              "Win",
+		Locale:          "enUS",
+		TimezoneBias:    60,
+		ClientIP:        [4]byte{127, 0, 0, 1},
+		Username:        "A",
+	}
+
+	t.Run("parse", func(t *testing.T) {
+		var p LogonChallenge
+		assert.NoError(t, p.UnmarshalBinary(vanillaChallenge))
+		assert.Equal(t, expected, p)
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		data, err := expected.MarshalBinary()
+		assert.NoError(t, err)
+		assert.Equal(t, vanillaChallenge, data)
+	})
+
+	t.Run("decode from stream leaves the next packet", func(t *testing.T) {
+		r := bytes.NewReader(append(append([]byte{}, vanillaChallenge...), 0xFF))
+		var p LogonChallenge
+		assert.NoError(t, p.Decode(r))
+		assert.Equal(t, 1, r.Len())
+	})
+
+	t.Run("game name is not reversed", func(t *testing.T) {
+		p := expected
+		p.GameName = "ABC"
+		data, err := p.MarshalBinary()
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{'A', 'B', 'C', 0}, data[4:8])
+
+		var parsed LogonChallenge
+		assert.NoError(t, parsed.UnmarshalBinary(data))
+		assert.Equal(t, "ABC", parsed.GameName)
+
+		p.GameName = "WoWWo"
+		_, err = p.MarshalBinary()
+		assert.ErrorIs(t, err, ErrStringTooLong)
+	})
+
+	t.Run("size mismatch", func(t *testing.T) {
+		data := append([]byte{}, vanillaChallenge...)
+		data[2]++
+		var p LogonChallenge
+		assert.ErrorIs(t, p.UnmarshalBinary(data), ErrInvalidSize)
+	})
+
+	t.Run("wrong opcode", func(t *testing.T) {
+		data := append([]byte{}, vanillaChallenge...)
+		data[0] = OpcodeLogonProof
+		var p LogonChallenge
+		assert.ErrorIs(t, p.UnmarshalBinary(data), ErrInvalidOpcode)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		for i := 0; i < len(vanillaChallenge); i++ {
+			var p LogonChallenge
+			assert.ErrorIs(t, p.UnmarshalBinary(vanillaChallenge[:i]), io.ErrUnexpectedEOF)
+		}
+	})
+
+	t.Run("trailing data", func(t *testing.T) {
+		var p LogonChallenge
+		data := append(append([]byte{}, vanillaChallenge...), 0)
+		assert.ErrorIs(t, p.UnmarshalBinary(data), ErrTrailingData)
+	})
+
+	t.Run("invalid strings", func(t *testing.T) {
+		p := expected
+		p.Locale = "enUSA"
+		_, err := p.MarshalBinary()
+		assert.ErrorIs(t, err, ErrStringTooLong)
+
+		p = expected
+		p.Username = string(make([]byte, 256))
+		_, err = p.MarshalBinary()
+		assert.ErrorIs(t, err, ErrStringTooLong)
+	})
+}
+
+func TestLogonChallengeReply(t *testing.T) {
+	newReply := func(protocolVersion uint8) *LogonChallengeReply {
+		return NewLogonChallengeReply(
+			protocolVersion,
+			bytes.Repeat([]byte{0xB}, srp.KeySize),
+			bytes.Repeat([]byte{0x5}, srp.SaltSize),
+			bytes.Repeat([]byte{0xC}, VersionChallengeSize),
+		)
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		full := newReply(8)
+		full.SecurityFlags = builds.SecurityFlagPIN | builds.SecurityFlagMatrixCard | builds.SecurityFlagAuthenticator
+		full.PINGridSeed = 0x12345678
+		full.PINSalt = bytes.Repeat([]byte{0xA}, PINSaltSize)
+		full.MatrixWidth = 8
+		full.MatrixHeight = 10
+		full.MatrixDigitCount = 2
+		full.MatrixChallengeCount = 3
+		full.MatrixSeed = 0x1122334455667788
+		full.AuthenticatorRequired = true
+
+		noFlags := newReply(8)
+		vanilla := newReply(3)
+		alpha := newReply(2)
+
+		for _, reply := range []*LogonChallengeReply{full, noFlags, vanilla, alpha} {
+			data, err := reply.MarshalBinary()
+			assert.NoError(t, err)
+
+			parsed := &LogonChallengeReply{ProtocolVersion: reply.ProtocolVersion}
+			assert.NoError(t, parsed.UnmarshalBinary(data))
+			assert.Equal(t, reply, parsed)
+		}
+	})
+
+	t.Run("protocol version 2 has no security flags", func(t *testing.T) {
+		v2, _ := newReply(2).MarshalBinary()
+		v3, _ := newReply(3).MarshalBinary()
+		assert.Equal(t, len(v2)+1, len(v3))
+	})
+
+	t.Run("layout", func(t *testing.T) {
+		data, err := newReply(3).MarshalBinary()
+		assert.NoError(t, err)
+		assert.Len(t, data, 3+srp.KeySize+2+1+srp.LargePrimeSize+srp.SaltSize+VersionChallengeSize+1)
+		assert.Equal(t, []byte{OpcodeLogonChallenge, 0, byte(LoginSuccess)}, data[:3])
+		assert.Equal(t, []byte{1, srp.Generator, srp.LargePrimeSize}, data[35:38])
+		assert.Equal(t, srp.LargePrime(), data[38:70])
+	})
+
+	t.Run("failure only sends the result", func(t *testing.T) {
+		reply := &LogonChallengeReply{ProtocolVersion: 8, Result: LoginFailUnknownAccount}
+		data, err := reply.MarshalBinary()
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{OpcodeLogonChallenge, 0, byte(LoginFailUnknownAccount)}, data)
+
+		parsed := &LogonChallengeReply{ProtocolVersion: 8}
+		assert.NoError(t, parsed.UnmarshalBinary(data))
+		assert.Equal(t, reply, parsed)
+	})
+
+	t.Run("invalid field sizes", func(t *testing.T) {
+		reply := newReply(8)
+		reply.Salt = reply.Salt[1:]
+		_, err := reply.MarshalBinary()
+		assert.ErrorIs(t, err, ErrInvalidField)
+
+		reply = newReply(8)
+		reply.SecurityFlags = builds.SecurityFlagPIN
+		_, err = reply.MarshalBinary()
+		assert.ErrorIs(t, err, ErrInvalidField)
+	})
+
+	t.Run("invalid prime length", func(t *testing.T) {
+		data, _ := newReply(8).MarshalBinary()
+		data[37] = srp.LargePrimeSize - 1
+		parsed := &LogonChallengeReply{ProtocolVersion: 8}
+		assert.ErrorIs(t, parsed.UnmarshalBinary(data), ErrInvalidField)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		data, _ := newReply(8).MarshalBinary()
+		for i := 0; i < len(data); i++ {
+			parsed := &LogonChallengeReply{ProtocolVersion: 8}
+			assert.ErrorIs(t, parsed.UnmarshalBinary(data[:i]), io.ErrUnexpectedEOF)
+		}
+	})
+}
@@ -0,0 +1,273 @@
+// Package authproto implements the wire format of the auth (logon) server packets.
+//
+// Every packet type can be serialized with MarshalBinary and parsed with UnmarshalBinary. Packets
+// can also be read directly from a stream with Decode, which reads exactly one packet, including
+// the leading opcode byte. Multi-byte integers are little endian unless noted otherwise, and keys,
+// salts and proofs are sent in the same byte order the srp package uses.
+package authproto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"strings"
+)
+
+// Opcodes of the auth server packets.
+const (
+	OpcodeLogonChallenge     = 0x00
+	OpcodeLogonProof         = 0x01
+	OpcodeReconnectChallenge = 0x02
+	OpcodeReconnectProof     = 0x03
+	OpcodeRealmList          = 0x10
+)
+
+var (
+	ErrInvalidOpcode = errors.New("srp/authproto: unexpected opcode")
+	ErrInvalidSize   = errors.New("srp/authproto: size field does not match packet contents")
+	ErrInvalidField  = errors.New("srp/authproto: field has an invalid length")
+	ErrStringTooLong = errors.New("srp/authproto: string is too long")
+	ErrTrailingData  = errors.New("srp/authproto: packet contains trailing data")
+)
+
+// decoder reads fields from a stream. The first error encountered is kept and all subsequent
+// reads become no-ops, so a packet can be decoded without checking the error after every field.
+type decoder struct {
+	r   io.Reader
+	buf [8]byte
+	err error
+}
+
+func (d *decoder) read(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	var buf []byte
+	if n <= len(d.buf) {
+		buf = d.buf[:n]
+	} else {
+		buf = make([]byte, n)
+	}
+	if _, err := io.ReadFull(d.r, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		d.err = err
+		return nil
+	}
+	return buf
+}
+
+func (d *decoder) opcode(expected uint8) {
+	if op := d.u8(); d.err == nil && op != expected {
+		d.err = ErrInvalidOpcode
+	}
+}
+
+func (d *decoder) u8() uint8 {
+	if b := d.read(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) u16() uint16 {
+	if b := d.read(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (d *decoder) u32() uint32 {
+	if b := d.read(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *decoder) u64() uint64 {
+	if b := d.read(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+// bytes returns a copy of the next n bytes.
+func (d *decoder) bytes(n int) []byte {
+	b := d.read(n)
+	if b == nil {
+		return nil
+	}
+	ret := make([]byte, n)
+	copy(ret, b)
+	return ret
+}
+
+// array fills dst with the next len(dst) bytes.
+func (d *decoder) array(dst []byte) {
+	if b := d.read(len(dst)); b != nil {
+		copy(dst, b)
+	}
+}
+
+// fourCC reads a 4 byte reversed, null padded string such as the platform ("x86").
+func (d *decoder) fourCC() string {
+	b := d.read(4)
+	if b == nil {
+		return ""
+	}
+	s := make([]byte, 0, 4)
+	for i := 3; i >= 0; i-- {
+		if b[i] != 0 {
+			s = append(s, b[i])
+		}
+	}
+	return string(s)
+}
+
+// paddedString reads a size byte, null padded string such as the game name ("WoW").
+func (d *decoder) paddedString(size int) string {
+	b := d.read(size)
+	if b == nil {
+		return ""
+	}
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+// string reads a string prefixed with its u8 length.
+func (d *decoder) string() string {
+	return string(d.bytes(int(d.u8())))
+}
+
+// cstring reads a null terminated string.
+func (d *decoder) cstring() string {
+	var s []byte
+	for {
+		c := d.u8()
+		if d.err != nil {
+			return ""
+		}
+		if c == 0 {
+			return string(s)
+		}
+		s = append(s, c)
+	}
+}
+
+func (d *decoder) f32() float32 {
+	return math.Float32frombits(d.u32())
+}
+
+// encoder appends fields to a byte slice.
+type encoder struct {
+	buf []byte
+	err error
+}
+
+func (e *encoder) u8(v uint8) {
+	e.buf = append(e.buf, v)
+}
+
+func (e *encoder) u16(v uint16) {
+	e.buf = binary.LittleEndian.AppendUint16(e.buf, v)
+}
+
+func (e *encoder) u32(v uint32) {
+	e.buf = binary.LittleEndian.AppendUint32(e.buf, v)
+}
+
+func (e *encoder) u64(v uint64) {
+	e.buf = binary.LittleEndian.AppendUint64(e.buf, v)
+}
+
+// fixed appends data, which must be exactly size bytes long.
+func (e *encoder) fixed(size int, data []byte) {
+	if len(data) != size {
+		e.fail(ErrInvalidField)
+		return
+	}
+	e.buf = append(e.buf, data...)
+}
+
+func (e *encoder) fourCC(s string) {
+	if len(s) > 4 {
+		e.fail(ErrStringTooLong)
+		return
+	}
+	var b [4]byte
+	for i := 0; i < len(s); i++ {
+		b[len(s)-i-1] = s[i]
+	}
+	e.buf = append(e.buf, b[:]...)
+}
+
+// paddedString appends s null padded to size bytes. Unlike fourCC, s is not reversed.
+func (e *encoder) paddedString(size int, s string) {
+	if len(s) > size {
+		e.fail(ErrStringTooLong)
+		return
+	}
+	if strings.IndexByte(s, 0) >= 0 {
+		e.fail(ErrInvalidField)
+		return
+	}
+	e.buf = append(e.buf, s...)
+	for i := len(s); i < size; i++ {
+		e.buf = append(e.buf, 0)
+	}
+}
+
+// string appends s prefixed with its u8 length.
+func (e *encoder) string(s string) {
+	if len(s) > math.MaxUint8 {
+		e.fail(ErrStringTooLong)
+		return
+	}
+	e.u8(uint8(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// cstring appends s followed by a null terminator.
+func (e *encoder) cstring(s string) {
+	if strings.IndexByte(s, 0) >= 0 {
+		e.fail(ErrInvalidField)
+		return
+	}
+	e.buf = append(e.buf, s...)
+	e.buf = append(e.buf, 0)
+}
+
+func (e *encoder) f32(v float32) {
+	e.u32(math.Float32bits(v))
+}
+
+func (e *encoder) fail(err error) {
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+func (e *encoder) bytes() ([]byte, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.buf, nil
+}
+
+// unmarshal decodes a single packet from data using decode, and returns an error if any data is
+// left over.
+func unmarshal(data []byte, decode func(r io.Reader) error) error {
+	r := bytes.NewReader(data)
+	if err := decode(r); err != nil {
+		return err
+	}
+	if r.Len() > 0 {
+		return ErrTrailingData
+	}
+	return nil
+}
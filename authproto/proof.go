@@ -0,0 +1,232 @@
+package authproto
+
+import (
+	"io"
+
+	srp "github.com/kangaroux/go-wow-srp6"
+	"github.com/kangaroux/go-wow-srp6/builds"
+)
+
+const (
+	CRCHashSize     = 20
+	PINHashSize     = 20
+	CDKeyProofSize  = 20
+	MatrixProofSize = 20
+)
+
+// TelemetryKey is sent by the client in [LogonProof]. Its fields are not used by the server.
+type TelemetryKey struct {
+	Unknown1   uint16
+	Unknown2   uint32
+	Unknown3   [4]byte
+	CDKeyProof [CDKeyProofSize]byte
+}
+
+// LogonProof is sent by the client after receiving [LogonChallengeReply] (CMD_AUTH_LOGON_PROOF).
+// ClientPublicKey and ClientProof can be passed directly to [srp.ClientChallengeProof] and
+// [srp.ServerChallengeProof].
+type LogonProof struct {
+	// ProtocolVersion is the protocol version sent in the logon challenge. It is not sent, but
+	// determines whether the security flags are included.
+	ProtocolVersion uint8
+
+	ClientPublicKey []byte
+	ClientProof     []byte
+
+	// CRCHash is a hash of the client's files using the version challenge.
+	CRCHash []byte
+
+	TelemetryKeys []TelemetryKey
+
+	// SecurityFlags is only sent for protocol version 3 and later, and should match the flags
+	// in the challenge reply.
+	SecurityFlags builds.SecurityFlag
+
+	PINSalt []byte
+	PINHash []byte
+
+	MatrixCardProof []byte
+
+	AuthenticatorToken string
+}
+
+// MarshalBinary returns the wire format of the proof, including the opcode.
+func (p *LogonProof) MarshalBinary() ([]byte, error) {
+	if len(p.TelemetryKeys) > 0xFF {
+		return nil, ErrInvalidField
+	}
+
+	e := &encoder{}
+	e.u8(OpcodeLogonProof)
+	e.fixed(srp.KeySize, p.ClientPublicKey)
+	e.fixed(srp.ProofSize, p.ClientProof)
+	e.fixed(CRCHashSize, p.CRCHash)
+	e.u8(uint8(len(p.TelemetryKeys)))
+
+	for _, key := range p.TelemetryKeys {
+		e.u16(key.Unknown1)
+		e.u32(key.Unknown2)
+		e.fixed(4, key.Unknown3[:])
+		e.fixed(CDKeyProofSize, key.CDKeyProof[:])
+	}
+
+	if p.ProtocolVersion < 3 {
+		return e.bytes()
+	}
+
+	e.u8(uint8(p.SecurityFlags))
+
+	if p.SecurityFlags&builds.SecurityFlagPIN != 0 {
+		e.fixed(PINSaltSize, p.PINSalt)
+		e.fixed(PINHashSize, p.PINHash)
+	}
+
+	if p.SecurityFlags&builds.SecurityFlagMatrixCard != 0 {
+		e.fixed(MatrixProofSize, p.MatrixCardProof)
+	}
+
+	if p.SecurityFlags&builds.SecurityFlagAuthenticator != 0 {
+		e.string(p.AuthenticatorToken)
+	}
+
+	return e.bytes()
+}
+
+// UnmarshalBinary parses a complete proof, including the opcode. ProtocolVersion must be set
+// before calling UnmarshalBinary.
+func (p *LogonProof) UnmarshalBinary(data []byte) error {
+	return unmarshal(data, p.Decode)
+}
+
+// Decode reads a single proof from r, including the opcode. ProtocolVersion must be set
+// before calling Decode.
+func (p *LogonProof) Decode(r io.Reader) error {
+	d := &decoder{r: r}
+	d.opcode(OpcodeLogonProof)
+	p.ClientPublicKey = d.bytes(srp.KeySize)
+	p.ClientProof = d.bytes(srp.ProofSize)
+	p.CRCHash = d.bytes(CRCHashSize)
+
+	p.TelemetryKeys = nil
+	numKeys := int(d.u8())
+	for i := 0; i < numKeys && d.err == nil; i++ {
+		var key TelemetryKey
+		key.Unknown1 = d.u16()
+		key.Unknown2 = d.u32()
+		d.array(key.Unknown3[:])
+		d.array(key.CDKeyProof[:])
+		p.TelemetryKeys = append(p.TelemetryKeys, key)
+	}
+
+	if d.err != nil || p.ProtocolVersion < 3 {
+		return d.err
+	}
+
+	p.SecurityFlags = builds.SecurityFlag(d.u8())
+
+	if p.SecurityFlags&builds.SecurityFlagPIN != 0 {
+		p.PINSalt = d.bytes(PINSaltSize)
+		p.PINHash = d.bytes(PINHashSize)
+	}
+
+	if p.SecurityFlags&builds.SecurityFlagMatrixCard != 0 {
+		p.MatrixCardProof = d.bytes(MatrixProofSize)
+	}
+
+	if p.SecurityFlags&builds.SecurityFlagAuthenticator != 0 {
+		p.AuthenticatorToken = d.string()
+	}
+
+	return d.err
+}
+
+// LogonProofReply is the server's reply to [LogonProof] (CMD_AUTH_LOGON_PROOF). If Result is not
+// [LoginSuccess], only the result is sent.
+type LogonProofReply struct {
+	// ProtocolVersion is the protocol version sent by the client. It is not sent, but determines
+	// which fields are included.
+	ProtocolVersion uint8
+
+	Result LoginResult
+
+	// ServerProof is the proof returned by [srp.ServerChallengeProof].
+	ServerProof []byte
+
+	// AccountFlags is only sent for protocol version 8 and later.
+	AccountFlags uint32
+
+	HardwareSurveyID uint32
+
+	// LoginFlags is only sent for protocol version 5 and later.
+	LoginFlags uint16
+}
+
+// NewLogonProofReply returns a successful reply containing serverProof.
+func NewLogonProofReply(protocolVersion uint8, serverProof []byte) *LogonProofReply {
+	return &LogonProofReply{
+		ProtocolVersion: protocolVersion,
+		Result:          LoginSuccess,
+		ServerProof:     serverProof,
+	}
+}
+
+// MarshalBinary returns the wire format of the reply, including the opcode.
+func (p *LogonProofReply) MarshalBinary() ([]byte, error) {
+	e := &encoder{}
+	e.u8(OpcodeLogonProof)
+	e.u8(p.Result.Wire(p.ProtocolVersion))
+
+	if p.Result != LoginSuccess {
+		if p.ProtocolVersion >= 3 {
+			e.u16(0)
+		}
+		return e.bytes()
+	}
+
+	e.fixed(srp.ProofSize, p.ServerProof)
+	if p.ProtocolVersion >= 8 {
+		e.u32(p.AccountFlags)
+	}
+	e.u32(p.HardwareSurveyID)
+	if p.ProtocolVersion >= 5 {
+		e.u16(p.LoginFlags)
+	}
+
+	return e.bytes()
+}
+
+// UnmarshalBinary parses a complete reply, including the opcode. ProtocolVersion must be set
+// before calling UnmarshalBinary.
+func (p *LogonProofReply) UnmarshalBinary(data []byte) error {
+	return unmarshal(data, p.Decode)
+}
+
+// Decode reads a single reply from r, including the opcode. ProtocolVersion must be set
+// before calling Decode.
+func (p *LogonProofReply) Decode(r io.Reader) error {
+	d := &decoder{r: r}
+	d.opcode(OpcodeLogonProof)
+	p.Result = LoginResult(d.u8())
+
+	if d.err != nil {
+		return d.err
+	}
+
+	if p.Result != LoginSuccess {
+		if p.ProtocolVersion >= 3 {
+			d.u16()
+		}
+		return d.err
+	}
+
+	p.ServerProof = d.bytes(srp.ProofSize)
+	if p.ProtocolVersion >= 8 {
+		p.AccountFlags = d.u32()
+	}
+	p.HardwareSurveyID = d.u32()
+	if p.ProtocolVersion >= 5 {
+		p.LoginFlags = d.u16()
+	}
+
+	return d.err
+}
@@ -0,0 +1,169 @@
+package authproto
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	srp "github.com/kangaroux/go-wow-srp6"
+	"github.com/kangaroux/go-wow-srp6/builds"
+	"github.com/kangaroux/go-wow-srp6/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func newLogonProof(protocolVersion uint8) *LogonProof {
+	return &LogonProof{
+		ProtocolVersion: protocolVersion,
+		ClientPublicKey: bytes.Repeat([]byte{0xA}, srp.KeySize),
+		ClientProof:     bytes.Repeat([]byte{0x1}, srp.ProofSize),
+		CRCHash:         bytes.Repeat([]byte{0xC}, CRCHashSize),
+	}
+}
+
+func TestLogonProof(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		full := newLogonProof(8)
+		full.TelemetryKeys = []TelemetryKey{
+			{Unknown1: 1, Unknown2: 2, Unknown3: [4]byte{3, 4, 5, 6}, CDKeyProof: [CDKeyProofSize]byte{7}},
+			{Unknown1: 8},
+		}
+		full.SecurityFlags = builds.SecurityFlagPIN | builds.SecurityFlagMatrixCard | builds.SecurityFlagAuthenticator
+		full.PINSalt = bytes.Repeat([]byte{0x2}, PINSaltSize)
+		full.PINHash = bytes.Repeat([]byte{0x3}, PINHashSize)
+		full.MatrixCardProof = bytes.Repeat([]byte{0x4}, MatrixProofSize)
+		full.AuthenticatorToken = "123456"
+
+		for _, proof := range []*LogonProof{full, newLogonProof(8), newLogonProof(3), newLogonProof(2)} {
+			data, err := proof.MarshalBinary()
+			assert.NoError(t, err)
+
+			parsed := &LogonProof{ProtocolVersion: proof.ProtocolVersion}
+			assert.NoError(t, parsed.UnmarshalBinary(data))
+			assert.Equal(t, proof, parsed)
+		}
+	})
+
+	t.Run("layout", func(t *testing.T) {
+		data, err := newLogonProof(3).MarshalBinary()
+		assert.NoError(t, err)
+		assert.Len(t, data, 1+srp.KeySize+srp.ProofSize+CRCHashSize+1+1)
+
+		proof := newLogonProof(3)
+		proof.TelemetryKeys = make([]TelemetryKey, 2)
+		withKeys, _ := proof.MarshalBinary()
+		assert.Len(t, withKeys, len(data)+60)
+		assert.Equal(t, uint8(2), withKeys[73])
+	})
+
+	t.Run("invalid field sizes", func(t *testing.T) {
+		proof := newLogonProof(8)
+		proof.ClientProof = proof.ClientProof[1:]
+		_, err := proof.MarshalBinary()
+		assert.ErrorIs(t, err, ErrInvalidField)
+
+		proof = newLogonProof(8)
+		proof.SecurityFlags = builds.SecurityFlagMatrixCard
+		_, err = proof.MarshalBinary()
+		assert.ErrorIs(t, err, ErrInvalidField)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		proof := newLogonProof(8)
+		proof.TelemetryKeys = make([]TelemetryKey, 1)
+		proof.SecurityFlags = builds.SecurityFlagAuthenticator
+		proof.AuthenticatorToken = "abc"
+		data, _ := proof.MarshalBinary()
+
+		for i := 0; i < len(data); i++ {
+			parsed := &LogonProof{ProtocolVersion: 8}
+			assert.ErrorIs(t, parsed.UnmarshalBinary(data[:i]), io.ErrUnexpectedEOF)
+		}
+	})
+
+	t.Run("client proof matches srp", func(t *testing.T) {
+		rows := internal.MustLoadTestData("../testdata/srp/calculate_client_proof.csv")
+
+		for _, row := range rows[:10] {
+			username := row[0]
+			salt := internal.MustDecodeHex(row[1])
+			serverPublic := internal.MustDecodeHex(row[3])
+			sessionKey := internal.MustDecodeHex(row[4])
+
+			proof := newLogonProof(8)
+			proof.ClientPublicKey = internal.MustDecodeHex(row[2])
+			proof.ClientProof = internal.MustDecodeHex(row[5])
+			data, _ := proof.MarshalBinary()
+
+			parsed := &LogonProof{ProtocolVersion: 8}
+			assert.NoError(t, parsed.UnmarshalBinary(data))
+			assert.Equal(t,
+				srp.ClientChallengeProof(username, salt, parsed.ClientPublicKey, serverPublic, sessionKey),
+				parsed.ClientProof,
+			)
+		}
+	})
+}
+
+func TestLogonProofReply(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		for _, version := range []uint8{2, 3, 5, 8} {
+			reply := NewLogonProofReply(version, bytes.Repeat([]byte{0xF}, srp.ProofSize))
+			if version >= 8 {
+				reply.AccountFlags = 0x01
+			}
+			reply.HardwareSurveyID = 5
+			if version >= 5 {
+				reply.LoginFlags = 0x02
+			}
+
+			data, err := reply.MarshalBinary()
+			assert.NoError(t, err)
+
+			parsed := &LogonProofReply{ProtocolVersion: version}
+			assert.NoError(t, parsed.UnmarshalBinary(data))
+			assert.Equal(t, reply, parsed)
+		}
+	})
+
+	t.Run("layout", func(t *testing.T) {
+		proof := bytes.Repeat([]byte{0xF}, srp.ProofSize)
+		sizes := map[uint8]int{2: 26, 3: 26, 5: 28, 8: 32}
+
+		for version, size := range sizes {
+			data, err := NewLogonProofReply(version, proof).MarshalBinary()
+			assert.NoError(t, err)
+			assert.Len(t, data, size)
+		}
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		reply := &LogonProofReply{ProtocolVersion: 8, Result: LoginFailIncorrectPassword}
+		data, err := reply.MarshalBinary()
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{OpcodeLogonProof, byte(LoginFailIncorrectPassword), 0, 0}, data)
+
+		parsed := &LogonProofReply{ProtocolVersion: 8}
+		assert.NoError(t, parsed.UnmarshalBinary(data))
+		assert.Equal(t, reply, parsed)
+
+		reply = &LogonProofReply{ProtocolVersion: 2, Result: LoginFailIncorrectPassword}
+		data, _ = reply.MarshalBinary()
+		assert.Equal(t, []byte{OpcodeLogonProof, byte(LoginFailIncorrectPassword)}, data)
+	})
+
+	t.Run("server proof matches srp", func(t *testing.T) {
+		rows := internal.MustLoadTestData("../testdata/srp/calculate_server_proof.csv")
+
+		for _, row := range rows[:10] {
+			clientPublic := internal.MustDecodeHex(row[0])
+			clientProof := internal.MustDecodeHex(row[1])
+			sessionKey := internal.MustDecodeHex(row[2])
+			expected := internal.MustDecodeHex(row[3])
+
+			reply := NewLogonProofReply(8, srp.ServerChallengeProof(clientPublic, clientProof, sessionKey))
+			data, err := reply.MarshalBinary()
+			assert.NoError(t, err)
+			assert.Equal(t, expected, data[2:22])
+		}
+	})
+}
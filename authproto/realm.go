@@ -0,0 +1,260 @@
+package authproto
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+)
+
+// RealmType is the ruleset of a realm.
+type RealmType uint32
+
+const (
+	RealmTypePvE   RealmType = 0
+	RealmTypePvP   RealmType = 1
+	RealmTypeRP    RealmType = 6
+	RealmTypeRPPvP RealmType = 8
+)
+
+func (t RealmType) String() string {
+	switch t {
+	case RealmTypePvE:
+		return "PvE"
+	case RealmTypePvP:
+		return "PvP"
+	case RealmTypeRP:
+		return "RP"
+	case RealmTypeRPPvP:
+		return "RP-PvP"
+	default:
+		return fmt.Sprintf("RealmType(%d)", uint32(t))
+	}
+}
+
+// RealmFlag changes how a realm is displayed in the realm list.
+type RealmFlag uint8
+
+const (
+	RealmFlagNone    RealmFlag = 0x00
+	RealmFlagInvalid RealmFlag = 0x01
+	RealmFlagOffline RealmFlag = 0x02
+
+	// RealmFlagSpecifyBuild includes [RealmVersion] in the realm entry. Only used in TBC and later.
+	RealmFlagSpecifyBuild RealmFlag = 0x04
+
+	RealmFlagNewPlayers  RealmFlag = 0x20
+	RealmFlagRecommended RealmFlag = 0x40
+	RealmFlagFull        RealmFlag = 0x80
+)
+
+// RealmVersion is the client version a realm expects. It is only sent when
+// [RealmFlagSpecifyBuild] is set.
+type RealmVersion struct {
+	Major uint8
+	Minor uint8
+	Patch uint8
+	Build uint16
+}
+
+// Realm is a single entry in the realm list.
+type Realm struct {
+	Type RealmType
+
+	// Locked is only sent in TBC and later.
+	Locked bool
+
+	Flags RealmFlag
+	Name  string
+
+	// Address is the "host:port" of the world server.
+	Address string
+
+	// Population is 0.0 for low, 1.0 for medium and 2.0 for high population. Values above 2.0
+	// are displayed as full.
+	Population float32
+
+	// CharacterCount is the number of characters the account has on the realm.
+	CharacterCount uint8
+
+	// Timezone is the realm category shown in the realm list tabs.
+	Timezone uint8
+
+	ID uint8
+
+	// Version is only sent in TBC and later, and only when [RealmFlagSpecifyBuild] is set.
+	Version RealmVersion
+}
+
+// RealmListRequest is sent by the client to request the realm list (CMD_REALM_LIST).
+type RealmListRequest struct{}
+
+// MarshalBinary returns the wire format of the request, including the opcode.
+func (p *RealmListRequest) MarshalBinary() ([]byte, error) {
+	e := &encoder{}
+	e.u8(OpcodeRealmList)
+	e.u32(0)
+	return e.bytes()
+}
+
+// UnmarshalBinary parses a complete request, including the opcode.
+func (p *RealmListRequest) UnmarshalBinary(data []byte) error {
+	return unmarshal(data, p.Decode)
+}
+
+// Decode reads a single request from r, including the opcode.
+func (p *RealmListRequest) Decode(r io.Reader) error {
+	d := &decoder{r: r}
+	d.opcode(OpcodeRealmList)
+	d.u32()
+	return d.err
+}
+
+// RealmList is the server's reply to [RealmListRequest] (CMD_REALM_LIST). The layout of each
+// realm depends on the protocol version: Vanilla clients (protocol version 3 and earlier) expect
+// a u32 realm type and no locked flag, while TBC and Wrath clients expect a u8 realm type, a
+// locked flag and an optional version.
+type RealmList struct {
+	// ProtocolVersion is the protocol version sent by the client. It is not sent, but determines
+	// the layout of the realm list.
+	ProtocolVersion uint8
+
+	Realms []Realm
+}
+
+// MarshalBinary returns the wire format of the realm list, including the opcode.
+func (p *RealmList) MarshalBinary() ([]byte, error) {
+	body := &encoder{}
+	body.u32(0)
+
+	if p.ProtocolVersion < 5 {
+		if len(p.Realms) > math.MaxUint8 {
+			return nil, ErrInvalidField
+		}
+		body.u8(uint8(len(p.Realms)))
+	} else {
+		if len(p.Realms) > math.MaxUint16 {
+			return nil, ErrInvalidField
+		}
+		body.u16(uint16(len(p.Realms)))
+	}
+
+	for i := range p.Realms {
+		p.encodeRealm(body, &p.Realms[i])
+	}
+
+	body.u16(0)
+
+	data, err := body.bytes()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > math.MaxUint16 {
+		return nil, ErrInvalidSize
+	}
+
+	e := &encoder{buf: make([]byte, 0, 3+len(data))}
+	e.u8(OpcodeRealmList)
+	e.u16(uint16(len(data)))
+	e.buf = append(e.buf, data...)
+	return e.bytes()
+}
+
+func (p *RealmList) encodeRealm(e *encoder, realm *Realm) {
+	if p.ProtocolVersion < 5 {
+		e.u32(uint32(realm.Type))
+	} else {
+		if realm.Type > math.MaxUint8 {
+			e.fail(ErrInvalidField)
+			return
+		}
+		e.u8(uint8(realm.Type))
+		e.u8(boolToByte(realm.Locked))
+	}
+
+	e.u8(uint8(realm.Flags))
+	e.cstring(realm.Name)
+	e.cstring(realm.Address)
+	e.f32(realm.Population)
+	e.u8(realm.CharacterCount)
+	e.u8(realm.Timezone)
+	e.u8(realm.ID)
+
+	if p.ProtocolVersion >= 5 && realm.Flags&RealmFlagSpecifyBuild != 0 {
+		e.u8(realm.Version.Major)
+		e.u8(realm.Version.Minor)
+		e.u8(realm.Version.Patch)
+		e.u16(realm.Version.Build)
+	}
+}
+
+// UnmarshalBinary parses a complete realm list, including the opcode. ProtocolVersion must be
+// set before calling UnmarshalBinary.
+func (p *RealmList) UnmarshalBinary(data []byte) error {
+	return unmarshal(data, p.Decode)
+}
+
+// Decode reads a single realm list from r, including the opcode. ProtocolVersion must be set
+// before calling Decode.
+func (p *RealmList) Decode(r io.Reader) error {
+	d := &decoder{r: r}
+	d.opcode(OpcodeRealmList)
+	size := d.u16()
+	data := d.read(int(size))
+	if d.err != nil {
+		return d.err
+	}
+
+	body := bytes.NewReader(data)
+	d = &decoder{r: body}
+	d.u32()
+
+	var numRealms int
+	if p.ProtocolVersion < 5 {
+		numRealms = int(d.u8())
+	} else {
+		numRealms = int(d.u16())
+	}
+
+	p.Realms = nil
+	for i := 0; i < numRealms && d.err == nil; i++ {
+		p.Realms = append(p.Realms, p.decodeRealm(d))
+	}
+
+	d.u16()
+
+	// The realm list is read in full before being parsed, so a realm list that is shorter than
+	// its size field is an invalid size, not an unexpected EOF.
+	if d.err == io.ErrUnexpectedEOF || (d.err == nil && body.Len() > 0) {
+		return ErrInvalidSize
+	}
+	return d.err
+}
+
+func (p *RealmList) decodeRealm(d *decoder) Realm {
+	var realm Realm
+
+	if p.ProtocolVersion < 5 {
+		realm.Type = RealmType(d.u32())
+	} else {
+		realm.Type = RealmType(d.u8())
+		realm.Locked = d.u8() != 0
+	}
+
+	realm.Flags = RealmFlag(d.u8())
+	realm.Name = d.cstring()
+	realm.Address = d.cstring()
+	realm.Population = d.f32()
+	realm.CharacterCount = d.u8()
+	realm.Timezone = d.u8()
+	realm.ID = d.u8()
+
+	if p.ProtocolVersion >= 5 && realm.Flags&RealmFlagSpecifyBuild != 0 {
+		realm.Version.Major = d.u8()
+		realm.Version.Minor = d.u8()
+		realm.Version.Patch = d.u8()
+		realm.Version.Build = d.u16()
+	}
+
+	return realm
+}
@@ -0,0 +1,131 @@
+package authproto
+
+import (
+	"io"
+	"testing"
+
+	"github.com/kangaroux/go-wow-srp6/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRealmListRequest(t *testing.T) {
+	data, err := (&RealmListRequest{}).MarshalBinary()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{OpcodeRealmList, 0, 0, 0, 0}, data)
+	assert.NoError(t, (&RealmListRequest{}).UnmarshalBinary(data))
+	assert.ErrorIs(t, (&RealmListRequest{}).UnmarshalBinary(data[:3]), io.ErrUnexpectedEOF)
+}
+
+func TestRealmList(t *testing.T) {
+	realm := Realm{
+		Type:           RealmTypePvP,
+		Flags:          RealmFlagRecommended,
+		Name:           "A",
+		Address:        "localhost:8085",
+		Population:     1.0,
+		CharacterCount: 3,
+		Timezone:       1,
+		ID:             2,
+	}
+
+	t.Run("vanilla layout", func(t *testing.T) {
+		list := &RealmList{ProtocolVersion: 3, Realms: []Realm{realm}}
+		data, err := list.MarshalBinary()
+		assert.NoError(t, err)
+
+		expected := internal.MustDecodeHex(
+			"102400" + // opcode, size
+				"00000000" + "01" + // padding, realm count
+				"01000000" + "40" + // type, flags
+				"4100" + "6C6F63616C686F73743A3830383500" + // name, address
+				"0000803F" + "03" + "01" + "02" + // population, characters, timezone, id
+				"0000", // footer
+		)
+		assert.Equal(t, expected, data)
+
+		parsed := &RealmList{ProtocolVersion: 3}
+		assert.NoError(t, parsed.UnmarshalBinary(data))
+		assert.Equal(t, list, parsed)
+	})
+
+	t.Run("tbc layout", func(t *testing.T) {
+		locked := realm
+		locked.Locked = true
+		list := &RealmList{ProtocolVersion: 8, Realms: []Realm{locked}}
+		data, err := list.MarshalBinary()
+		assert.NoError(t, err)
+
+		expected := internal.MustDecodeHex(
+			"102300" + // opcode, size
+				"00000000" + "0100" + // padding, realm count
+				"01" + "01" + "40" + // type, locked, flags
+				"4100" + "6C6F63616C686F73743A3830383500" + // name, address
+				"0000803F" + "03" + "01" + "02" + // population, characters, timezone, id
+				"0000", // footer
+		)
+		assert.Equal(t, expected, data)
+
+		parsed := &RealmList{ProtocolVersion: 8}
+		assert.NoError(t, parsed.UnmarshalBinary(data))
+		assert.Equal(t, list, parsed)
+	})
+
+	t.Run("version is only sent with specify build", func(t *testing.T) {
+		withBuild := realm
+		withBuild.Flags |= RealmFlagSpecifyBuild
+		withBuild.Version = RealmVersion{Major: 3, Minor: 3, Patch: 5, Build: 12340}
+
+		list := &RealmList{ProtocolVersion: 8, Realms: []Realm{realm, withBuild}}
+		data, err := list.MarshalBinary()
+		assert.NoError(t, err)
+
+		parsed := &RealmList{ProtocolVersion: 8}
+		assert.NoError(t, parsed.UnmarshalBinary(data))
+		assert.Equal(t, list, parsed)
+
+		without := &RealmList{ProtocolVersion: 8, Realms: []Realm{realm, realm}}
+		withoutData, _ := without.MarshalBinary()
+		assert.Equal(t, len(withoutData)+5, len(data))
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		for _, version := range []uint8{3, 8} {
+			list := &RealmList{ProtocolVersion: version}
+			data, err := list.MarshalBinary()
+			assert.NoError(t, err)
+
+			parsed := &RealmList{ProtocolVersion: version}
+			assert.NoError(t, parsed.UnmarshalBinary(data))
+			assert.Equal(t, list, parsed)
+		}
+	})
+
+	t.Run("invalid size", func(t *testing.T) {
+		data, _ := (&RealmList{ProtocolVersion: 8, Realms: []Realm{realm}}).MarshalBinary()
+
+		short := append([]byte{}, data...)
+		short[1]--
+		assert.ErrorIs(t, (&RealmList{ProtocolVersion: 8}).UnmarshalBinary(short[:len(short)-1]), ErrInvalidSize)
+
+		long := append(append([]byte{}, data...), 0)
+		long[1]++
+		assert.ErrorIs(t, (&RealmList{ProtocolVersion: 8}).UnmarshalBinary(long), ErrInvalidSize)
+
+		assert.ErrorIs(t, (&RealmList{ProtocolVersion: 8}).UnmarshalBinary(data[:len(data)-1]), io.ErrUnexpectedEOF)
+	})
+
+	t.Run("invalid fields", func(t *testing.T) {
+		invalid := realm
+		invalid.Name = "A\x00B"
+		_, err := (&RealmList{ProtocolVersion: 8, Realms: []Realm{invalid}}).MarshalBinary()
+		assert.ErrorIs(t, err, ErrInvalidField)
+
+		invalid = realm
+		invalid.Type = 0x100
+		_, err = (&RealmList{ProtocolVersion: 8, Realms: []Realm{invalid}}).MarshalBinary()
+		assert.ErrorIs(t, err, ErrInvalidField)
+
+		_, err = (&RealmList{ProtocolVersion: 3, Realms: make([]Realm, 256)}).MarshalBinary()
+		assert.ErrorIs(t, err, ErrInvalidField)
+	})
+}
@@ -0,0 +1,161 @@
+package authproto
+
+import (
+	"io"
+
+	srp "github.com/kangaroux/go-wow-srp6"
+)
+
+const (
+	ChecksumSaltSize   = 16
+	ClientChecksumSize = 20
+)
+
+// ReconnectChallenge is sent by the client to begin reconnecting (CMD_AUTH_RECONNECT_CHALLENGE).
+// It has the same layout as [LogonChallenge].
+type ReconnectChallenge LogonChallenge
+
+// MarshalBinary returns the wire format of the challenge, including the opcode.
+func (p *ReconnectChallenge) MarshalBinary() ([]byte, error) {
+	return (*LogonChallenge)(p).marshal(OpcodeReconnectChallenge)
+}
+
+// UnmarshalBinary parses a complete challenge packet, including the opcode.
+func (p *ReconnectChallenge) UnmarshalBinary(data []byte) error {
+	return unmarshal(data, p.Decode)
+}
+
+// Decode reads a single challenge packet from r, including the opcode.
+func (p *ReconnectChallenge) Decode(r io.Reader) error {
+	return (*LogonChallenge)(p).decode(r, OpcodeReconnectChallenge)
+}
+
+// ReconnectChallengeReply is the server's reply to [ReconnectChallenge]
+// (CMD_AUTH_RECONNECT_CHALLENGE). If Result is not [LoginSuccess], only the result is sent.
+type ReconnectChallengeReply struct {
+	// ProtocolVersion is the protocol version sent by the client. It is not sent, but determines
+	// the wire value of Result.
+	ProtocolVersion uint8
+
+	Result LoginResult
+
+	// ChallengeData is random data generated by the server. It is the serverData argument
+	// of [srp.ReconnectProof].
+	ChallengeData []byte
+
+	ChecksumSalt []byte
+}
+
+// MarshalBinary returns the wire format of the reply, including the opcode.
+func (p *ReconnectChallengeReply) MarshalBinary() ([]byte, error) {
+	e := &encoder{}
+	e.u8(OpcodeReconnectChallenge)
+	e.u8(p.Result.Wire(p.ProtocolVersion))
+
+	if p.Result == LoginSuccess {
+		e.fixed(srp.ProofDataSize, p.ChallengeData)
+		e.fixed(ChecksumSaltSize, p.ChecksumSalt)
+	}
+
+	return e.bytes()
+}
+
+// UnmarshalBinary parses a complete reply, including the opcode.
+func (p *ReconnectChallengeReply) UnmarshalBinary(data []byte) error {
+	return unmarshal(data, p.Decode)
+}
+
+// Decode reads a single reply from r, including the opcode.
+func (p *ReconnectChallengeReply) Decode(r io.Reader) error {
+	d := &decoder{r: r}
+	d.opcode(OpcodeReconnectChallenge)
+	p.Result = LoginResult(d.u8())
+
+	if d.err == nil && p.Result == LoginSuccess {
+		p.ChallengeData = d.bytes(srp.ProofDataSize)
+		p.ChecksumSalt = d.bytes(ChecksumSaltSize)
+	}
+
+	return d.err
+}
+
+// ReconnectProof is sent by the client after receiving [ReconnectChallengeReply]
+// (CMD_AUTH_RECONNECT_PROOF).
+type ReconnectProof struct {
+	// ProofData is random data generated by the client (R1). It is the clientData argument
+	// of [srp.ReconnectProof].
+	ProofData []byte
+
+	// ClientProof is the result of [srp.ReconnectProof] (R2).
+	ClientProof []byte
+
+	// ClientChecksum is a hash of the client's files using the checksum salt (R3).
+	ClientChecksum []byte
+
+	KeyCount uint8
+}
+
+// MarshalBinary returns the wire format of the proof, including the opcode.
+func (p *ReconnectProof) MarshalBinary() ([]byte, error) {
+	e := &encoder{}
+	e.u8(OpcodeReconnectProof)
+	e.fixed(srp.ProofDataSize, p.ProofData)
+	e.fixed(srp.ProofSize, p.ClientProof)
+	e.fixed(ClientChecksumSize, p.ClientChecksum)
+	e.u8(p.KeyCount)
+	return e.bytes()
+}
+
+// UnmarshalBinary parses a complete proof, including the opcode.
+func (p *ReconnectProof) UnmarshalBinary(data []byte) error {
+	return unmarshal(data, p.Decode)
+}
+
+// Decode reads a single proof from r, including the opcode.
+func (p *ReconnectProof) Decode(r io.Reader) error {
+	d := &decoder{r: r}
+	d.opcode(OpcodeReconnectProof)
+	p.ProofData = d.bytes(srp.ProofDataSize)
+	p.ClientProof = d.bytes(srp.ProofSize)
+	p.ClientChecksum = d.bytes(ClientChecksumSize)
+	p.KeyCount = d.u8()
+	return d.err
+}
+
+// ReconnectProofReply is the server's reply to [ReconnectProof] (CMD_AUTH_RECONNECT_PROOF).
+type ReconnectProofReply struct {
+	// ProtocolVersion is the protocol version sent by the client. It is not sent, but determines
+	// whether padding is included.
+	ProtocolVersion uint8
+
+	Result LoginResult
+}
+
+// MarshalBinary returns the wire format of the reply, including the opcode.
+func (p *ReconnectProofReply) MarshalBinary() ([]byte, error) {
+	e := &encoder{}
+	e.u8(OpcodeReconnectProof)
+	e.u8(p.Result.Wire(p.ProtocolVersion))
+	if p.ProtocolVersion >= 5 {
+		e.u16(0)
+	}
+	return e.bytes()
+}
+
+// UnmarshalBinary parses a complete reply, including the opcode. ProtocolVersion must be set
+// before calling UnmarshalBinary.
+func (p *ReconnectProofReply) UnmarshalBinary(data []byte) error {
+	return unmarshal(data, p.Decode)
+}
+
+// Decode reads a single reply from r, including the opcode. ProtocolVersion must be set
+// before calling Decode.
+func (p *ReconnectProofReply) Decode(r io.Reader) error {
+	d := &decoder{r: r}
+	d.opcode(OpcodeReconnectProof)
+	p.Result = LoginResult(d.u8())
+	if p.ProtocolVersion >= 5 {
+		d.u16()
+	}
+	return d.err
+}
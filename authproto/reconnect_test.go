@@ -0,0 +1,136 @@
+package authproto
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	srp "github.com/kangaroux/go-wow-srp6"
+	"github.com/kangaroux/go-wow-srp6/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReconnectChallenge(t *testing.T) {
+	t.Run("same layout as logon challenge", func(t *testing.T) {
+		var logon LogonChallenge
+		assert.NoError(t, logon.UnmarshalBinary(vanillaChallenge))
+
+		reconnect := ReconnectChallenge(logon)
+		data, err := reconnect.MarshalBinary()
+		assert.NoError(t, err)
+		assert.Equal(t, uint8(OpcodeReconnectChallenge), data[0])
+		assert.Equal(t, vanillaChallenge[1:], data[1:])
+
+		var parsed ReconnectChallenge
+		assert.NoError(t, parsed.UnmarshalBinary(data))
+		assert.Equal(t, reconnect, parsed)
+	})
+
+	t.Run("wrong opcode", func(t *testing.T) {
+		var p ReconnectChallenge
+		assert.ErrorIs(t, p.UnmarshalBinary(vanillaChallenge), ErrInvalidOpcode)
+	})
+}
+
+func TestReconnectChallengeReply(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		reply := &ReconnectChallengeReply{
+			Result:        LoginSuccess,
+			ChallengeData: bytes.Repeat([]byte{0x1}, srp.ProofDataSize),
+			ChecksumSalt:  bytes.Repeat([]byte{0x2}, ChecksumSaltSize),
+		}
+		data, err := reply.MarshalBinary()
+		assert.NoError(t, err)
+		assert.Len(t, data, 2+srp.ProofDataSize+ChecksumSaltSize)
+
+		parsed := &ReconnectChallengeReply{}
+		assert.NoError(t, parsed.UnmarshalBinary(data))
+		assert.Equal(t, reply, parsed)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		reply := &ReconnectChallengeReply{Result: LoginFailBanned}
+		data, err := reply.MarshalBinary()
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{OpcodeReconnectChallenge, byte(LoginFailBanned)}, data)
+	})
+
+	t.Run("invalid challenge data", func(t *testing.T) {
+		reply := &ReconnectChallengeReply{
+			ChallengeData: make([]byte, srp.ProofDataSize-1),
+			ChecksumSalt:  make([]byte, ChecksumSaltSize),
+		}
+		_, err := reply.MarshalBinary()
+		assert.ErrorIs(t, err, ErrInvalidField)
+	})
+}
+
+func TestReconnectProof(t *testing.T) {
+	rows := internal.MustLoadTestData("../testdata/srp/calculate_reconnect_proof.csv")
+
+	t.Run("generated test data", func(t *testing.T) {
+		for _, row := range rows {
+			username := row[0]
+			clientData := internal.MustDecodeHex(row[1])
+			serverData := internal.MustDecodeHex(row[2])
+			sessionKey := internal.MustDecodeHex(row[3])
+			expected := internal.MustDecodeHex(row[4])
+
+			challengeData, _ := (&ReconnectChallengeReply{
+				ChallengeData: serverData,
+				ChecksumSalt:  make([]byte, ChecksumSaltSize),
+			}).MarshalBinary()
+			proofData, err := (&ReconnectProof{
+				ProofData:      clientData,
+				ClientProof:    expected,
+				ClientChecksum: make([]byte, ClientChecksumSize),
+			}).MarshalBinary()
+			assert.NoError(t, err)
+
+			challenge := &ReconnectChallengeReply{}
+			proof := &ReconnectProof{}
+			assert.NoError(t, challenge.UnmarshalBinary(challengeData))
+			assert.NoError(t, proof.UnmarshalBinary(proofData))
+			assert.Equal(t,
+				srp.ReconnectProof(username, proof.ProofData, challenge.ChallengeData, sessionKey),
+				proof.ClientProof,
+			)
+		}
+	})
+
+	t.Run("round trip", func(t *testing.T) {
+		proof := &ReconnectProof{
+			ProofData:      internal.MustDecodeHex(rows[0][1]),
+			ClientProof:    internal.MustDecodeHex(rows[0][4]),
+			ClientChecksum: bytes.Repeat([]byte{0x3}, ClientChecksumSize),
+			KeyCount:       1,
+		}
+		data, err := proof.MarshalBinary()
+		assert.NoError(t, err)
+		assert.Len(t, data, 1+srp.ProofDataSize+srp.ProofSize+ClientChecksumSize+1)
+
+		parsed := &ReconnectProof{}
+		assert.NoError(t, parsed.UnmarshalBinary(data))
+		assert.Equal(t, proof, parsed)
+
+		for i := 0; i < len(data); i++ {
+			assert.ErrorIs(t, parsed.UnmarshalBinary(data[:i]), io.ErrUnexpectedEOF)
+		}
+	})
+}
+
+func TestReconnectProofReply(t *testing.T) {
+	for version, expected := range map[uint8][]byte{
+		2: {OpcodeReconnectProof, byte(LoginSuccessSurvey)},
+		8: {OpcodeReconnectProof, byte(LoginSuccessSurvey), 0, 0},
+	} {
+		reply := &ReconnectProofReply{ProtocolVersion: version, Result: LoginSuccessSurvey}
+		data, err := reply.MarshalBinary()
+		assert.NoError(t, err)
+		assert.Equal(t, expected, data)
+
+		parsed := &ReconnectProofReply{ProtocolVersion: version}
+		assert.NoError(t, parsed.UnmarshalBinary(data))
+		assert.Equal(t, reply, parsed)
+	}
+}
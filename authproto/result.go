@@ -0,0 +1,143 @@
+package authproto
+
+import (
+	"errors"
+	"fmt"
+
+	srp "github.com/kangaroux/go-wow-srp6"
+)
+
+// LoginResult is the result of a login step, sent in the challenge and proof replies. The values
+// match the wire values of protocol version 8. Older clients don't understand every result, use
+// [LoginResult.Wire] to get the value to send.
+type LoginResult uint8
+
+const (
+	LoginSuccess                LoginResult = 0x00
+	LoginFailUnknown0           LoginResult = 0x01
+	LoginFailUnknown1           LoginResult = 0x02
+	LoginFailBanned             LoginResult = 0x03
+	LoginFailUnknownAccount     LoginResult = 0x04
+	LoginFailIncorrectPassword  LoginResult = 0x05
+	LoginFailAlreadyOnline      LoginResult = 0x06
+	LoginFailNoTime             LoginResult = 0x07
+	LoginFailDBBusy             LoginResult = 0x08
+	LoginFailVersionInvalid     LoginResult = 0x09
+	LoginDownloadFile           LoginResult = 0x0A
+	LoginFailInvalidServer      LoginResult = 0x0B
+	LoginFailSuspended          LoginResult = 0x0C
+	LoginFailNoAccess           LoginResult = 0x0D
+	LoginSuccessSurvey          LoginResult = 0x0E
+	LoginFailParentalControl    LoginResult = 0x0F
+	LoginFailLockedEnforced     LoginResult = 0x10
+	LoginFailTrialEnded         LoginResult = 0x11
+	LoginFailUseBattlenet       LoginResult = 0x12
+	LoginFailAntiIndulgence     LoginResult = 0x13
+	LoginFailExpired            LoginResult = 0x14
+	LoginFailNoGameAccount      LoginResult = 0x15
+	LoginFailChargeback         LoginResult = 0x16
+	LoginFailGameRoomWithoutNet LoginResult = 0x17
+	LoginFailGameAccountLocked  LoginResult = 0x18
+	LoginFailUnlockableLock     LoginResult = 0x19
+	LoginFailConversionRequired LoginResult = 0x20
+	LoginFailDisconnected       LoginResult = 0xFF
+)
+
+var loginResultNames = map[LoginResult]string{
+	LoginSuccess:                "Success",
+	LoginFailUnknown0:           "FailUnknown0",
+	LoginFailUnknown1:           "FailUnknown1",
+	LoginFailBanned:             "FailBanned",
+	LoginFailUnknownAccount:     "FailUnknownAccount",
+	LoginFailIncorrectPassword:  "FailIncorrectPassword",
+	LoginFailAlreadyOnline:      "FailAlreadyOnline",
+	LoginFailNoTime:             "FailNoTime",
+	LoginFailDBBusy:             "FailDBBusy",
+	LoginFailVersionInvalid:     "FailVersionInvalid",
+	LoginDownloadFile:           "DownloadFile",
+	LoginFailInvalidServer:      "FailInvalidServer",
+	LoginFailSuspended:          "FailSuspended",
+	LoginFailNoAccess:           "FailNoAccess",
+	LoginSuccessSurvey:          "SuccessSurvey",
+	LoginFailParentalControl:    "FailParentalControl",
+	LoginFailLockedEnforced:     "FailLockedEnforced",
+	LoginFailTrialEnded:         "FailTrialEnded",
+	LoginFailUseBattlenet:       "FailUseBattlenet",
+	LoginFailAntiIndulgence:     "FailAntiIndulgence",
+	LoginFailExpired:            "FailExpired",
+	LoginFailNoGameAccount:      "FailNoGameAccount",
+	LoginFailChargeback:         "FailChargeback",
+	LoginFailGameRoomWithoutNet: "FailGameRoomWithoutNet",
+	LoginFailGameAccountLocked:  "FailGameAccountLocked",
+	LoginFailUnlockableLock:     "FailUnlockableLock",
+	LoginFailConversionRequired: "FailConversionRequired",
+	LoginFailDisconnected:       "FailDisconnected",
+}
+
+func (r LoginResult) String() string {
+	if name, ok := loginResultNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("LoginResult(0x%02X)", uint8(r))
+}
+
+// Supported returns true if clients using protocolVersion understand the result.
+func (r LoginResult) Supported(protocolVersion uint8) bool {
+	if _, ok := loginResultNames[r]; !ok {
+		return false
+	}
+
+	switch {
+	case protocolVersion < 5:
+		return r <= LoginFailParentalControl
+	case protocolVersion < 8:
+		return r <= LoginFailLockedEnforced
+	default:
+		return true
+	}
+}
+
+// Wire returns the value to send to clients using protocolVersion. Results the client doesn't
+// understand are replaced with the closest result it does.
+func (r LoginResult) Wire(protocolVersion uint8) uint8 {
+	if r.Supported(protocolVersion) {
+		return uint8(r)
+	}
+
+	switch r {
+	case LoginFailTrialEnded, LoginFailExpired:
+		return uint8(LoginFailNoTime)
+	case LoginFailLockedEnforced, LoginFailGameAccountLocked, LoginFailUnlockableLock:
+		return uint8(LoginFailNoAccess)
+	case LoginFailChargeback, LoginFailAntiIndulgence:
+		return uint8(LoginFailSuspended)
+	case LoginFailNoGameAccount:
+		return uint8(LoginFailUnknownAccount)
+	default:
+		return uint8(LoginFailUnknown0)
+	}
+}
+
+// ResultFromError returns the result to send to the client for err. Errors from the srp
+// package's Verify functions map to [LoginFailIncorrectPassword]. Errors that implement
+// LoginResult() [LoginResult] map to that result. A nil error is [LoginSuccess] and any other
+// error is [LoginFailDBBusy].
+func ResultFromError(err error) LoginResult {
+	if err == nil {
+		return LoginSuccess
+	}
+
+	var resulter interface{ LoginResult() LoginResult }
+	if errors.As(err, &resulter) {
+		return resulter.LoginResult()
+	}
+
+	switch {
+	case errors.Is(err, srp.ErrInvalidPublicKey),
+		errors.Is(err, srp.ErrInvalidClientProof),
+		errors.Is(err, srp.ErrInvalidReconnectProof):
+		return LoginFailIncorrectPassword
+	default:
+		return LoginFailDBBusy
+	}
+}
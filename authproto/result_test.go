@@ -0,0 +1,55 @@
+package authproto
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	srp "github.com/kangaroux/go-wow-srp6"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginResultString(t *testing.T) {
+	assert.Equal(t, "Success", LoginSuccess.String())
+	assert.Equal(t, "FailIncorrectPassword", LoginFailIncorrectPassword.String())
+	assert.Equal(t, "LoginResult(0x30)", LoginResult(0x30).String())
+}
+
+func TestLoginResultWire(t *testing.T) {
+	t.Run("supported results are unchanged", func(t *testing.T) {
+		assert.Equal(t, uint8(0x03), LoginFailBanned.Wire(3))
+		assert.Equal(t, uint8(0x10), LoginFailLockedEnforced.Wire(5))
+		assert.Equal(t, uint8(0x18), LoginFailGameAccountLocked.Wire(8))
+	})
+
+	t.Run("unsupported results are replaced", func(t *testing.T) {
+		assert.Equal(t, uint8(LoginFailNoAccess), LoginFailLockedEnforced.Wire(3))
+		assert.Equal(t, uint8(LoginFailNoTime), LoginFailTrialEnded.Wire(5))
+		assert.Equal(t, uint8(LoginFailUnknownAccount), LoginFailNoGameAccount.Wire(3))
+		assert.Equal(t, uint8(LoginFailUnknown0), LoginFailUseBattlenet.Wire(3))
+		assert.Equal(t, uint8(LoginFailUnknown0), LoginResult(0x30).Wire(8))
+	})
+
+	t.Run("supported", func(t *testing.T) {
+		assert.True(t, LoginFailParentalControl.Supported(3))
+		assert.False(t, LoginFailLockedEnforced.Supported(3))
+		assert.True(t, LoginFailLockedEnforced.Supported(5))
+		assert.False(t, LoginFailTrialEnded.Supported(5))
+		assert.True(t, LoginFailDisconnected.Supported(8))
+	})
+}
+
+type resultError struct{}
+
+func (resultError) Error() string            { return "result error" }
+func (resultError) LoginResult() LoginResult { return LoginFailSuspended }
+
+func TestResultFromError(t *testing.T) {
+	assert.Equal(t, LoginSuccess, ResultFromError(nil))
+	assert.Equal(t, LoginFailIncorrectPassword, ResultFromError(srp.ErrInvalidClientProof))
+	assert.Equal(t, LoginFailIncorrectPassword, ResultFromError(srp.ErrInvalidReconnectProof))
+	assert.Equal(t, LoginFailIncorrectPassword, ResultFromError(srp.ErrInvalidPublicKey))
+	assert.Equal(t, LoginFailIncorrectPassword, ResultFromError(fmt.Errorf("wrapped: %w", srp.ErrInvalidClientProof)))
+	assert.Equal(t, LoginFailSuspended, ResultFromError(fmt.Errorf("wrapped: %w", resultError{})))
+	assert.Equal(t, LoginFailDBBusy, ResultFromError(errors.New("database is down")))
+}
@@ -0,0 +1,147 @@
+package authproto
+
+import (
+	"crypto/md5"
+	"io"
+	"math"
+)
+
+// Opcodes of the patch transfer packets.
+const (
+	OpcodeXferInitiate = 0x30
+	OpcodeXferData     = 0x31
+	OpcodeXferAccept   = 0x32
+	OpcodeXferResume   = 0x33
+	OpcodeXferCancel   = 0x34
+)
+
+// XferInitiate is sent by the server to begin a patch transfer (CMD_XFER_INITIATE).
+type XferInitiate struct {
+	// Filename is "Patch" for client patches.
+	Filename string
+	FileSize uint64
+	FileMD5  []byte
+}
+
+// MarshalBinary returns the wire format of the packet, including the opcode.
+func (p *XferInitiate) MarshalBinary() ([]byte, error) {
+	e := &encoder{}
+	e.u8(OpcodeXferInitiate)
+	e.string(p.Filename)
+	e.u64(p.FileSize)
+	e.fixed(md5.Size, p.FileMD5)
+	return e.bytes()
+}
+
+// UnmarshalBinary parses a complete packet, including the opcode.
+func (p *XferInitiate) UnmarshalBinary(data []byte) error {
+	return unmarshal(data, p.Decode)
+}
+
+// Decode reads a single packet from r, including the opcode.
+func (p *XferInitiate) Decode(r io.Reader) error {
+	d := &decoder{r: r}
+	d.opcode(OpcodeXferInitiate)
+	p.Filename = d.string()
+	p.FileSize = d.u64()
+	p.FileMD5 = d.bytes(md5.Size)
+	return d.err
+}
+
+// XferData is a chunk of the file being transferred (CMD_XFER_DATA).
+type XferData struct {
+	Data []byte
+}
+
+// MarshalBinary returns the wire format of the packet, including the opcode.
+func (p *XferData) MarshalBinary() ([]byte, error) {
+	if len(p.Data) > math.MaxUint16 {
+		return nil, ErrInvalidField
+	}
+
+	e := &encoder{buf: make([]byte, 0, 3+len(p.Data))}
+	e.u8(OpcodeXferData)
+	e.u16(uint16(len(p.Data)))
+	e.buf = append(e.buf, p.Data...)
+	return e.bytes()
+}
+
+// UnmarshalBinary parses a complete packet, including the opcode.
+func (p *XferData) UnmarshalBinary(data []byte) error {
+	return unmarshal(data, p.Decode)
+}
+
+// Decode reads a single packet from r, including the opcode.
+func (p *XferData) Decode(r io.Reader) error {
+	d := &decoder{r: r}
+	d.opcode(OpcodeXferData)
+	p.Data = d.bytes(int(d.u16()))
+	return d.err
+}
+
+// XferAccept is sent by the client to accept a transfer from the beginning (CMD_XFER_ACCEPT).
+type XferAccept struct{}
+
+// MarshalBinary returns the wire format of the packet, including the opcode.
+func (p *XferAccept) MarshalBinary() ([]byte, error) {
+	return []byte{OpcodeXferAccept}, nil
+}
+
+// UnmarshalBinary parses a complete packet, including the opcode.
+func (p *XferAccept) UnmarshalBinary(data []byte) error {
+	return unmarshal(data, p.Decode)
+}
+
+// Decode reads a single packet from r, including the opcode.
+func (p *XferAccept) Decode(r io.Reader) error {
+	d := &decoder{r: r}
+	d.opcode(OpcodeXferAccept)
+	return d.err
+}
+
+// XferResume is sent by the client to accept a transfer starting at Offset, when part of the
+// file was already downloaded (CMD_XFER_RESUME).
+type XferResume struct {
+	Offset uint64
+}
+
+// MarshalBinary returns the wire format of the packet, including the opcode.
+func (p *XferResume) MarshalBinary() ([]byte, error) {
+	e := &encoder{}
+	e.u8(OpcodeXferResume)
+	e.u64(p.Offset)
+	return e.bytes()
+}
+
+// UnmarshalBinary parses a complete packet, including the opcode.
+func (p *XferResume) UnmarshalBinary(data []byte) error {
+	return unmarshal(data, p.Decode)
+}
+
+// Decode reads a single packet from r, including the opcode.
+func (p *XferResume) Decode(r io.Reader) error {
+	d := &decoder{r: r}
+	d.opcode(OpcodeXferResume)
+	p.Offset = d.u64()
+	return d.err
+}
+
+// XferCancel is sent by the client to decline or abort a transfer (CMD_XFER_CANCEL).
+type XferCancel struct{}
+
+// MarshalBinary returns the wire format of the packet, including the opcode.
+func (p *XferCancel) MarshalBinary() ([]byte, error) {
+	return []byte{OpcodeXferCancel}, nil
+}
+
+// UnmarshalBinary parses a complete packet, including the opcode.
+func (p *XferCancel) UnmarshalBinary(data []byte) error {
+	return unmarshal(data, p.Decode)
+}
+
+// Decode reads a single packet from r, including the opcode.
+func (p *XferCancel) Decode(r io.Reader) error {
+	d := &decoder{r: r}
+	d.opcode(OpcodeXferCancel)
+	return d.err
+}
@@ -0,0 +1,55 @@
+package authproto
+
+import (
+	"bytes"
+	"encoding"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXfer(t *testing.T) {
+	type packet interface {
+		encoding.BinaryMarshaler
+		encoding.BinaryUnmarshaler
+	}
+
+	cases := []struct {
+		packet   packet
+		empty    packet
+		expected []byte
+	}{
+		{
+			&XferInitiate{Filename: "Patch", FileSize: 0x0102, FileMD5: bytes.Repeat([]byte{0xA}, 16)},
+			&XferInitiate{},
+			append([]byte{OpcodeXferInitiate, 5, 'P', 'a', 't', 'c', 'h', 2, 1, 0, 0, 0, 0, 0, 0}, bytes.Repeat([]byte{0xA}, 16)...),
+		},
+		{&XferData{Data: []byte{1, 2, 3}}, &XferData{}, []byte{OpcodeXferData, 3, 0, 1, 2, 3}},
+		{&XferAccept{}, &XferAccept{}, []byte{OpcodeXferAccept}},
+		{&XferResume{Offset: 0x0201}, &XferResume{}, []byte{OpcodeXferResume, 1, 2, 0, 0, 0, 0, 0, 0}},
+		{&XferCancel{}, &XferCancel{}, []byte{OpcodeXferCancel}},
+	}
+
+	for _, c := range cases {
+		data, err := c.packet.MarshalBinary()
+		assert.NoError(t, err)
+		assert.Equal(t, c.expected, data)
+		assert.NoError(t, c.empty.UnmarshalBinary(data))
+		assert.Equal(t, c.packet, c.empty)
+
+		for i := 0; i < len(data); i++ {
+			assert.ErrorIs(t, c.empty.UnmarshalBinary(data[:i]), io.ErrUnexpectedEOF)
+		}
+	}
+}
+
+func TestXferInvalid(t *testing.T) {
+	_, err := (&XferData{Data: make([]byte, 0x10000)}).MarshalBinary()
+	assert.ErrorIs(t, err, ErrInvalidField)
+
+	_, err = (&XferInitiate{Filename: "Patch", FileMD5: make([]byte, 15)}).MarshalBinary()
+	assert.ErrorIs(t, err, ErrInvalidField)
+
+	assert.ErrorIs(t, (&XferAccept{}).UnmarshalBinary([]byte{OpcodeXferCancel}), ErrInvalidOpcode)
+}
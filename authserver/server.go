@@ -0,0 +1,488 @@
+// Package authserver is a small, embeddable auth (logon) server built on the srp and authproto
+// packages.
+//
+// The server handles the logon challenge and proof, the reconnect challenge and proof, and the
+// realm list. Accounts are looked up with an [AccountStore], and the session key is sent to a
+// [SessionKeyStore] once the client has logged in, where the world server can pick it up.
+package authserver
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	srp "github.com/kangaroux/go-wow-srp6"
+	"github.com/kangaroux/go-wow-srp6/authproto"
+	"github.com/kangaroux/go-wow-srp6/builds"
+)
+
+var ErrUnexpectedPacket = errors.New("srp/authserver: unexpected packet")
+
+// LoginError is returned by [Server.ServeConn] when the client failed to log in. The result has
+// already been sent to the client.
+type LoginError struct {
+	Result authproto.LoginResult
+
+	// Err is the reason the login failed, if there is one.
+	Err error
+}
+
+func (e *LoginError) Error() string {
+	if e.Err != nil {
+		return "srp/authserver: login failed: " + e.Result.String() + ": " + e.Err.Error()
+	}
+	return "srp/authserver: login failed: " + e.Result.String()
+}
+
+func (e *LoginError) Unwrap() error {
+	return e.Err
+}
+
+// LoginResult returns the result that was sent to the client.
+func (e *LoginError) LoginResult() authproto.LoginResult {
+	return e.Result
+}
+
+// Server is an auth server. The zero value is not usable, Accounts and Sessions must be set.
+type Server struct {
+	Accounts AccountStore
+	Sessions SessionKeyStore
+
+	// Realms returns the realm list for the logged in account. If Realms is nil, the realm list
+	// is empty.
+	Realms func(ctx context.Context, username string) ([]authproto.Realm, error)
+
+	// UnknownAccountSecret hides which accounts exist. If it's set, clients which try an unknown
+	// account are sent a fake challenge derived from the secret (see [srp.NewUnknownHandshake]),
+	// and fail the proof with [authproto.LoginFailIncorrectPassword] after the same work as a real
+	// account. If it's nil, unknown accounts are rejected with
+	// [authproto.LoginFailUnknownAccount].
+	UnknownAccountSecret []byte
+
+	// Limiter limits failed login attempts. If Limiter is nil, attempts are not limited.
+	Limiter Limiter
+
+	// Replay rejects logon proofs which reuse a client public key, and reconnect proofs which
+	// reuse proof data. If Replay is nil, replays are only stopped by the server's random
+	// challenge.
+	Replay ReplayGuard
+
+	// Timeout is how long the server waits for each packet from the client. Zero means no timeout.
+	Timeout time.Duration
+
+	// Rand is the source of the server's private keys and challenge data. Defaults to
+	// [crypto/rand.Reader].
+	Rand io.Reader
+}
+
+// Serve accepts connections on l and handles each of them in a new goroutine. Serve closes l and
+// all open connections once ctx is done, waits for the connections to finish, and returns the
+// context's error. If Accept fails, the open connections are closed the same way and Serve
+// returns the error.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	ctx, cancel := context.WithCancel(ctx)
+
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	// Close the connections before waiting for them
+	var wg sync.WaitGroup
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.ServeConn(ctx, conn)
+		}()
+	}
+}
+
+// ServeConn handles a single client connection until the client disconnects, an error occurs,
+// or ctx is done. ServeConn always closes conn. Errors which are part of the login flow, such as
+// an incorrect password, are sent to the client and returned.
+func (s *Server) ServeConn(ctx context.Context, conn net.Conn) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	c := &serverConn{
+		server: s,
+		conn:   conn,
+		r:      bufio.NewReader(conn),
+		ip:     remoteIP(conn),
+	}
+
+	err := c.serve(ctx)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+type connState int
+
+const (
+	stateInit connState = iota
+	stateChallenged
+	stateReconnectChallenged
+	stateAuthenticated
+)
+
+// serverConn holds the state of a single client connection.
+type serverConn struct {
+	server *Server
+	conn   net.Conn
+	r      *bufio.Reader
+	ip     string
+
+	state           connState
+	protocolVersion uint8
+	username        string
+
+	// Logon challenge. The account is nil if it doesn't exist and the handshake is fake.
+	account   *Account
+	handshake *srp.Handshake
+
+	// Reconnect challenge
+	sessionKey    []byte
+	challengeData []byte
+}
+
+func (c *serverConn) serve(ctx context.Context) error {
+	for {
+		if c.server.Timeout > 0 {
+			c.conn.SetReadDeadline(time.Now().Add(c.server.Timeout))
+		}
+
+		opcode, err := c.r.Peek(1)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case opcode[0] == authproto.OpcodeLogonChallenge && c.state == stateInit:
+			err = c.handleLogonChallenge(ctx)
+		case opcode[0] == authproto.OpcodeLogonProof && c.state == stateChallenged:
+			err = c.handleLogonProof(ctx)
+		case opcode[0] == authproto.OpcodeReconnectChallenge && c.state == stateInit:
+			err = c.handleReconnectChallenge(ctx)
+		case opcode[0] == authproto.OpcodeReconnectProof && c.state == stateReconnectChallenged:
+			err = c.handleReconnectProof(ctx)
+		case opcode[0] == authproto.OpcodeRealmList && c.state == stateAuthenticated:
+			err = c.handleRealmList(ctx)
+		default:
+			err = ErrUnexpectedPacket
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (c *serverConn) handleLogonChallenge(ctx context.Context) error {
+	p := &authproto.LogonChallenge{}
+	if err := p.Decode(c.r); err != nil {
+		return err
+	}
+
+	reply := &authproto.LogonChallengeReply{ProtocolVersion: p.ProtocolVersion}
+
+	result, err := c.checkChallenge(ctx, p, false)
+	if result != authproto.LoginSuccess {
+		reply.Result = result
+		return c.fail(reply, result, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	serverPrivateKey, err := c.server.randomBytes(srp.KeySize)
+	if err != nil {
+		return err
+	}
+	if c.account != nil {
+		c.handshake = srp.NewHandshake(c.username, c.account.Salt, c.account.Verifier, serverPrivateKey)
+	} else {
+		c.handshake = srp.NewUnknownHandshake(c.username, c.server.UnknownAccountSecret, serverPrivateKey)
+	}
+
+	versionChallenge, err := c.server.randomBytes(authproto.VersionChallengeSize)
+	if err != nil {
+		return err
+	}
+
+	reply = authproto.NewLogonChallengeReply(p.ProtocolVersion, c.handshake.ServerPublicKey(), c.handshake.Salt(), versionChallenge)
+	if err := c.write(reply); err != nil {
+		return err
+	}
+
+	c.state = stateChallenged
+	return nil
+}
+
+// checkChallenge checks the build, asks the limiter if the client may log in, and looks up the
+// account. It returns the result to send to the client and the reason the login failed, if there
+// is one. If the result is a success but the error is set, the account lookup failed. Unknown
+// accounts succeed with a nil account if the server hides them, except when reconnecting.
+func (c *serverConn) checkChallenge(ctx context.Context, p *authproto.LogonChallenge, reconnect bool) (authproto.LoginResult, error) {
+	c.protocolVersion = p.ProtocolVersion
+	if !builds.IsSupported(p.Build) {
+		return authproto.LoginFailVersionInvalid, nil
+	}
+	c.username = strings.ToUpper(p.Username)
+
+	if c.server.Limiter != nil {
+		if err := c.server.Limiter.Allow(ctx, c.username, c.ip); err != nil {
+			return authproto.ResultFromError(err), err
+		}
+	}
+
+	account, err := c.server.Accounts.Account(ctx, c.username)
+	if errors.Is(err, ErrAccountNotFound) {
+		if c.server.UnknownAccountSecret != nil && !reconnect {
+			return authproto.LoginSuccess, nil
+		}
+		return authproto.LoginFailUnknownAccount, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	if account.Banned {
+		return authproto.LoginFailBanned, nil
+	}
+	if account.Suspended(time.Now()) {
+		return authproto.LoginFailSuspended, nil
+	}
+
+	c.account = account
+	return authproto.LoginSuccess, nil
+}
+
+func (c *serverConn) handleLogonProof(ctx context.Context) error {
+	p := &authproto.LogonProof{ProtocolVersion: c.protocolVersion}
+	if err := p.Decode(c.r); err != nil {
+		return err
+	}
+
+	sessionKey, serverProof, err := c.handshake.Verify(p.ClientPublicKey, p.ClientProof)
+	if err != nil && c.account == nil {
+		err = fmt.Errorf("%w: %w", ErrAccountNotFound, err)
+	}
+	if err == nil {
+		err = c.checkReplay(ctx, replayLogon, p.ClientPublicKey)
+	}
+	if err != nil {
+		err = c.attemptFailed(ctx, err)
+	} else if err = c.attemptSucceeded(ctx); err == nil {
+		err = c.server.Sessions.SaveSessionKey(ctx, c.username, sessionKey)
+	}
+	if err != nil {
+		result := authproto.ResultFromError(err)
+		return c.fail(&authproto.LogonProofReply{
+			ProtocolVersion: c.protocolVersion,
+			Result:          result,
+		}, result, err)
+	}
+
+	if err := c.write(authproto.NewLogonProofReply(c.protocolVersion, serverProof)); err != nil {
+		return err
+	}
+
+	c.state = stateAuthenticated
+	return nil
+}
+
+func (c *serverConn) handleReconnectChallenge(ctx context.Context) error {
+	p := &authproto.ReconnectChallenge{}
+	if err := p.Decode(c.r); err != nil {
+		return err
+	}
+
+	reply := &authproto.ReconnectChallengeReply{ProtocolVersion: p.ProtocolVersion}
+
+	result, err := c.checkChallenge(ctx, (*authproto.LogonChallenge)(p), true)
+	if result != authproto.LoginSuccess {
+		reply.Result = result
+		return c.fail(reply, result, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	c.sessionKey, err = c.server.Sessions.SessionKey(ctx, c.username)
+	if errors.Is(err, ErrSessionNotFound) {
+		reply.Result = authproto.LoginFailUnknownAccount
+		return c.fail(reply, reply.Result, err)
+	} else if err != nil {
+		return err
+	}
+
+	if c.challengeData, err = c.server.randomBytes(srp.ProofDataSize); err != nil {
+		return err
+	}
+	if reply.ChecksumSalt, err = c.server.randomBytes(authproto.ChecksumSaltSize); err != nil {
+		return err
+	}
+
+	reply.ChallengeData = c.challengeData
+	if err := c.write(reply); err != nil {
+		return err
+	}
+
+	c.state = stateReconnectChallenged
+	return nil
+}
+
+func (c *serverConn) handleReconnectProof(ctx context.Context) error {
+	p := &authproto.ReconnectProof{}
+	if err := p.Decode(c.r); err != nil {
+		return err
+	}
+
+	reply := &authproto.ReconnectProofReply{ProtocolVersion: c.protocolVersion}
+
+	err := srp.VerifyReconnectProof(c.username, p.ProofData, c.challengeData, c.sessionKey, p.ClientProof)
+	if err == nil {
+		err = c.checkReplay(ctx, replayReconnect, p.ProofData)
+	}
+	if err != nil {
+		err = c.attemptFailed(ctx, err)
+	} else {
+		err = c.attemptSucceeded(ctx)
+	}
+	if err != nil {
+		reply.Result = authproto.ResultFromError(err)
+		return c.fail(reply, reply.Result, err)
+	}
+
+	if err := c.write(reply); err != nil {
+		return err
+	}
+
+	c.state = stateAuthenticated
+	return nil
+}
+
+func (c *serverConn) handleRealmList(ctx context.Context) error {
+	if err := (&authproto.RealmListRequest{}).Decode(c.r); err != nil {
+		return err
+	}
+
+	list := &authproto.RealmList{ProtocolVersion: c.protocolVersion}
+
+	if c.server.Realms != nil {
+		realms, err := c.server.Realms(ctx, c.username)
+		if err != nil {
+			return err
+		}
+		list.Realms = realms
+	}
+
+	return c.write(list)
+}
+
+// Labels passed to the replay guard, so data from different proofs is never compared.
+var (
+	replayLogon     = []byte("logon proof")
+	replayReconnect = []byte("reconnect proof")
+)
+
+// checkReplay checks the client's data with the replay guard. It's only called once the proof
+// is verified, so clients which don't know the password can't fill the guard.
+func (c *serverConn) checkReplay(ctx context.Context, label, data []byte) error {
+	if c.server.Replay == nil {
+		return nil
+	}
+	return c.server.Replay.Check(ctx, c.username, label, data)
+}
+
+// attemptFailed tells the limiter the client sent an incorrect proof. It returns err, or the
+// limiter's error if it failed.
+func (c *serverConn) attemptFailed(ctx context.Context, err error) error {
+	if c.server.Limiter == nil {
+		return err
+	}
+	if limitErr := c.server.Limiter.Failed(ctx, c.username, c.ip); limitErr != nil {
+		return limitErr
+	}
+	return err
+}
+
+// attemptSucceeded tells the limiter the client logged in.
+func (c *serverConn) attemptSucceeded(ctx context.Context) error {
+	if c.server.Limiter == nil {
+		return nil
+	}
+	return c.server.Limiter.Succeeded(ctx, c.username, c.ip)
+}
+
+// write sends a single packet to the client.
+func (c *serverConn) write(p interface{ MarshalBinary() ([]byte, error) }) error {
+	data, err := p.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = c.conn.Write(data)
+	return err
+}
+
+// fail sends a failed reply to the client and returns a [LoginError]. The connection is closed
+// once the error is returned.
+func (c *serverConn) fail(p interface{ MarshalBinary() ([]byte, error) }, result authproto.LoginResult, err error) error {
+	if err := c.write(p); err != nil {
+		return err
+	}
+	return &LoginError{Result: result, Err: err}
+}
+
+// randomBytes returns n bytes read from s.Rand.
+func (s *Server) randomBytes(n int) ([]byte, error) {
+	r := s.Rand
+	if r == nil {
+		r = rand.Reader
+	}
+
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// remoteIP returns the IP address of the client, or the full address if it doesn't have a port.
+func remoteIP(conn net.Conn) string {
+	addr := conn.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
@@ -0,0 +1,525 @@
+package authserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	srp "github.com/kangaroux/go-wow-srp6"
+	"github.com/kangaroux/go-wow-srp6/authproto"
+	"github.com/kangaroux/go-wow-srp6/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testUsername = "ALICE"
+	testPassword = "PASSWORD123"
+)
+
+// A valid client public key from the generated test data.
+var testClientPublicKey = internal.MustDecodeHex("9DB3B411728B4BA3C7DA58D34C13D4989CED729EB2151826AA4B6F0BF994620F")
+
+type testAccounts map[string]*Account
+
+func (a testAccounts) Account(ctx context.Context, username string) (*Account, error) {
+	if account, ok := a[username]; ok {
+		return account, nil
+	}
+	return nil, ErrAccountNotFound
+}
+
+// fixedReader is a deterministic source of "random" data, so the test knows the server's
+// private key.
+type fixedReader byte
+
+func (r fixedReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(r)
+	}
+	return len(p), nil
+}
+
+var testPrivateKey = bytes.Repeat([]byte{0x42}, srp.KeySize)
+
+type testConn struct {
+	t      *testing.T
+	conn   net.Conn
+	done   chan error
+	server *Server
+}
+
+func newTestServer() *Server {
+	salt := bytes.Repeat([]byte{0x5A}, srp.SaltSize)
+	return &Server{
+		Accounts: testAccounts{
+			testUsername: {
+				Username: testUsername,
+				Salt:     salt,
+				Verifier: srp.PasswordVerifier(testUsername, testPassword, salt),
+			},
+			"BANNED": {Username: "BANNED", Salt: salt, Verifier: salt, Banned: true},
+			"SUSPENDED": {
+				Username:       "SUSPENDED",
+				Salt:           salt,
+				Verifier:       salt,
+				SuspendedUntil: time.Now().Add(time.Hour),
+			},
+		},
+		Sessions: NewMemorySessionKeyStore(),
+		Rand:     fixedReader(0x42),
+		Realms: func(ctx context.Context, username string) ([]authproto.Realm, error) {
+			return []authproto.Realm{{Name: "Test", Address: "localhost:8085", CharacterCount: 1}}, nil
+		},
+	}
+}
+
+func connect(t *testing.T, s *Server) *testConn {
+	serverConn, clientConn := net.Pipe()
+	c := &testConn{t: t, conn: clientConn, done: make(chan error, 1), server: s}
+
+	go func() {
+		c.done <- s.ServeConn(context.Background(), serverConn)
+	}()
+
+	t.Cleanup(func() { clientConn.Close() })
+	return c
+}
+
+func (c *testConn) send(p interface{ MarshalBinary() ([]byte, error) }) {
+	data, err := p.MarshalBinary()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	c.conn.SetDeadline(time.Now().Add(time.Second))
+	if _, err := c.conn.Write(data); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *testConn) wait() error {
+	select {
+	case err := <-c.done:
+		return err
+	case <-time.After(time.Second):
+		c.t.Fatal("timed out waiting for ServeConn to return")
+		return nil
+	}
+}
+
+func challenge(username string, build uint16) *authproto.LogonChallenge {
+	return &authproto.LogonChallenge{
+		ProtocolVersion: 8,
+		GameName:        "WoW",
+		Major:           3,
+		Minor:           3,
+		Patch:           5,
+		Build:           build,
+		Platform:        "x86",
+		OS:              "Win",
+		Locale:          "enUS",
+		Username:        username,
+	}
+}
+
+// login sends the logon challenge and proof and returns the proof reply, the session key and the
+// client proof.
+func (c *testConn) login(username string, correct bool) (*authproto.LogonProofReply, []byte, []byte) {
+	c.send(challenge(username, 12340))
+
+	reply := &authproto.LogonChallengeReply{ProtocolVersion: 8}
+	assert.NoError(c.t, reply.Decode(c.conn))
+	assert.Equal(c.t, authproto.LoginSuccess, reply.Result)
+
+	account, _ := c.server.Accounts.Account(context.Background(), username)
+	sessionKey := srp.SessionKey(testClientPublicKey, reply.ServerPublicKey, testPrivateKey, account.Verifier)
+	if !correct {
+		sessionKey[0] ^= 1
+	}
+
+	clientProof := srp.ClientChallengeProof(username, reply.Salt, testClientPublicKey, reply.ServerPublicKey, sessionKey)
+	c.send(&authproto.LogonProof{
+		ProtocolVersion: 8,
+		ClientPublicKey: testClientPublicKey,
+		ClientProof:     clientProof,
+		CRCHash:         make([]byte, authproto.CRCHashSize),
+	})
+
+	proofReply := &authproto.LogonProofReply{ProtocolVersion: 8}
+	assert.NoError(c.t, proofReply.Decode(c.conn))
+	return proofReply, sessionKey, clientProof
+}
+
+func TestLogin(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		s := newTestServer()
+		c := connect(t, s)
+
+		reply, sessionKey, clientProof := c.login(testUsername, true)
+		assert.Equal(t, authproto.LoginSuccess, reply.Result)
+		assert.Equal(t, srp.ServerChallengeProof(testClientPublicKey, clientProof, sessionKey), reply.ServerProof)
+
+		saved, err := s.Sessions.SessionKey(context.Background(), testUsername)
+		assert.NoError(t, err)
+		assert.Equal(t, sessionKey, saved)
+
+		c.send(&authproto.RealmListRequest{})
+		list := &authproto.RealmList{ProtocolVersion: 8}
+		assert.NoError(t, list.Decode(c.conn))
+		assert.Len(t, list.Realms, 1)
+		assert.Equal(t, "Test", list.Realms[0].Name)
+
+		c.conn.Close()
+		assert.NoError(t, c.wait())
+	})
+
+	t.Run("incorrect password", func(t *testing.T) {
+		s := newTestServer()
+		c := connect(t, s)
+
+		reply, _, _ := c.login(testUsername, false)
+		assert.Equal(t, authproto.LoginFailIncorrectPassword, reply.Result)
+
+		var loginErr *LoginError
+		err := c.wait()
+		assert.ErrorAs(t, err, &loginErr)
+		assert.ErrorIs(t, err, srp.ErrInvalidClientProof)
+		assert.Equal(t, authproto.LoginFailIncorrectPassword, loginErr.Result)
+
+		_, err = s.Sessions.SessionKey(context.Background(), testUsername)
+		assert.ErrorIs(t, err, ErrSessionNotFound)
+	})
+
+	t.Run("invalid public key", func(t *testing.T) {
+		c := connect(t, newTestServer())
+		c.send(challenge(testUsername, 12340))
+		assert.NoError(t, (&authproto.LogonChallengeReply{ProtocolVersion: 8}).Decode(c.conn))
+
+		c.send(&authproto.LogonProof{
+			ProtocolVersion: 8,
+			ClientPublicKey: make([]byte, srp.KeySize),
+			ClientProof:     make([]byte, srp.ProofSize),
+			CRCHash:         make([]byte, authproto.CRCHashSize),
+		})
+
+		reply := &authproto.LogonProofReply{ProtocolVersion: 8}
+		assert.NoError(t, reply.Decode(c.conn))
+		assert.Equal(t, authproto.LoginFailIncorrectPassword, reply.Result)
+		assert.ErrorIs(t, c.wait(), srp.ErrInvalidPublicKey)
+	})
+
+	t.Run("challenge failures", func(t *testing.T) {
+		cases := []struct {
+			username string
+			build    uint16
+			expected authproto.LoginResult
+		}{
+			{"NOBODY", 12340, authproto.LoginFailUnknownAccount},
+			{"BANNED", 12340, authproto.LoginFailBanned},
+			{"SUSPENDED", 12340, authproto.LoginFailSuspended},
+			{testUsername, 1234, authproto.LoginFailVersionInvalid},
+		}
+
+		for _, tc := range cases {
+			c := connect(t, newTestServer())
+			c.send(challenge(tc.username, tc.build))
+
+			reply := &authproto.LogonChallengeReply{ProtocolVersion: 8}
+			assert.NoError(t, reply.Decode(c.conn))
+			assert.Equal(t, tc.expected, reply.Result)
+
+			var loginErr *LoginError
+			assert.ErrorAs(t, c.wait(), &loginErr)
+			assert.Equal(t, tc.expected, loginErr.Result)
+		}
+	})
+
+	t.Run("hidden unknown account", func(t *testing.T) {
+		s := newTestServer()
+		s.UnknownAccountSecret = bytes.Repeat([]byte{0x33}, srp.UnknownAccountSecretSize)
+		c := connect(t, s)
+		c.send(challenge("NOBODY", 12340))
+
+		reply := &authproto.LogonChallengeReply{ProtocolVersion: 8}
+		assert.NoError(t, reply.Decode(c.conn))
+		assert.Equal(t, authproto.LoginSuccess, reply.Result)
+		assert.Len(t, reply.Salt, srp.SaltSize)
+
+		c.send(&authproto.LogonProof{
+			ProtocolVersion: 8,
+			ClientPublicKey: testClientPublicKey,
+			ClientProof:     make([]byte, srp.ProofSize),
+			CRCHash:         make([]byte, authproto.CRCHashSize),
+		})
+
+		proofReply := &authproto.LogonProofReply{ProtocolVersion: 8}
+		assert.NoError(t, proofReply.Decode(c.conn))
+		assert.Equal(t, authproto.LoginFailIncorrectPassword, proofReply.Result)
+
+		err := c.wait()
+		assert.ErrorIs(t, err, ErrAccountNotFound)
+		assert.ErrorIs(t, err, srp.ErrInvalidClientProof)
+	})
+
+	t.Run("username is case insensitive", func(t *testing.T) {
+		c := connect(t, newTestServer())
+		c.send(challenge("alice", 12340))
+		challengeReply := &authproto.LogonChallengeReply{ProtocolVersion: 8}
+		assert.NoError(t, challengeReply.Decode(c.conn))
+		assert.Equal(t, authproto.LoginSuccess, challengeReply.Result)
+	})
+}
+
+func TestReconnect(t *testing.T) {
+	reconnect := func(c *testConn, sessionKey []byte) *authproto.ReconnectProofReply {
+		c.send((*authproto.ReconnectChallenge)(challenge(testUsername, 12340)))
+
+		reply := &authproto.ReconnectChallengeReply{}
+		assert.NoError(t, reply.Decode(c.conn))
+		assert.Equal(t, authproto.LoginSuccess, reply.Result)
+
+		clientData := bytes.Repeat([]byte{0x11}, srp.ProofDataSize)
+		c.send(&authproto.ReconnectProof{
+			ProofData:      clientData,
+			ClientProof:    srp.ReconnectProof(testUsername, clientData, reply.ChallengeData, sessionKey),
+			ClientChecksum: make([]byte, authproto.ClientChecksumSize),
+		})
+
+		proofReply := &authproto.ReconnectProofReply{ProtocolVersion: 8}
+		assert.NoError(t, proofReply.Decode(c.conn))
+		return proofReply
+	}
+
+	t.Run("success", func(t *testing.T) {
+		s := newTestServer()
+		c := connect(t, s)
+		_, sessionKey, _ := c.login(testUsername, true)
+		c.conn.Close()
+		c.wait()
+
+		c = connect(t, s)
+		assert.Equal(t, authproto.LoginSuccess, reconnect(c, sessionKey).Result)
+
+		c.send(&authproto.RealmListRequest{})
+		assert.NoError(t, (&authproto.RealmList{ProtocolVersion: 8}).Decode(c.conn))
+	})
+
+	t.Run("wrong session key", func(t *testing.T) {
+		s := newTestServer()
+		s.Sessions.SaveSessionKey(context.Background(), testUsername, make([]byte, srp.SessionKeySize))
+		c := connect(t, s)
+
+		assert.Equal(t, authproto.LoginFailIncorrectPassword, reconnect(c, bytes.Repeat([]byte{1}, srp.SessionKeySize)).Result)
+		assert.ErrorIs(t, c.wait(), srp.ErrInvalidReconnectProof)
+	})
+
+	t.Run("no session", func(t *testing.T) {
+		c := connect(t, newTestServer())
+		c.send((*authproto.ReconnectChallenge)(challenge(testUsername, 12340)))
+
+		reply := &authproto.ReconnectChallengeReply{}
+		assert.NoError(t, reply.Decode(c.conn))
+		assert.Equal(t, authproto.LoginFailUnknownAccount, reply.Result)
+		assert.ErrorIs(t, c.wait(), ErrSessionNotFound)
+	})
+}
+
+// testLimiter records the calls to it, and rejects the client if allowErr is set.
+type testLimiter struct {
+	allowErr error
+	calls    []string
+}
+
+func (l *testLimiter) Allow(ctx context.Context, username, ip string) error {
+	l.calls = append(l.calls, "allow "+username+" "+ip)
+	return l.allowErr
+}
+
+func (l *testLimiter) Failed(ctx context.Context, username, ip string) error {
+	l.calls = append(l.calls, "failed "+username+" "+ip)
+	return nil
+}
+
+func (l *testLimiter) Succeeded(ctx context.Context, username, ip string) error {
+	l.calls = append(l.calls, "succeeded "+username+" "+ip)
+	return nil
+}
+
+type suspendedError struct{}
+
+func (suspendedError) Error() string                      { return "suspended" }
+func (suspendedError) LoginResult() authproto.LoginResult { return authproto.LoginFailSuspended }
+
+func TestLimiter(t *testing.T) {
+	t.Run("attempts", func(t *testing.T) {
+		limiter := &testLimiter{}
+		s := newTestServer()
+		s.Limiter = limiter
+
+		c := connect(t, s)
+		c.login(testUsername, false)
+		c.wait()
+
+		c = connect(t, s)
+		c.login(testUsername, true)
+		c.conn.Close()
+		c.wait()
+
+		// net.Pipe addresses don't have a port
+		assert.Equal(t, []string{
+			"allow ALICE pipe",
+			"failed ALICE pipe",
+			"allow ALICE pipe",
+			"succeeded ALICE pipe",
+		}, limiter.calls)
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		s := newTestServer()
+		s.Limiter = &testLimiter{allowErr: suspendedError{}}
+
+		c := connect(t, s)
+		c.send(challenge(testUsername, 12340))
+
+		reply := &authproto.LogonChallengeReply{ProtocolVersion: 8}
+		assert.NoError(t, reply.Decode(c.conn))
+		assert.Equal(t, authproto.LoginFailSuspended, reply.Result)
+		assert.ErrorIs(t, c.wait(), suspendedError{})
+	})
+}
+
+// testReplayGuard rejects data it has seen before.
+type testReplayGuard map[string]bool
+
+func (g testReplayGuard) Check(ctx context.Context, username string, data ...[]byte) error {
+	key := string(bytes.Join(append([][]byte{[]byte(username)}, data...), []byte{0}))
+	if g[key] {
+		return suspendedError{}
+	}
+	g[key] = true
+	return nil
+}
+
+func TestReplay(t *testing.T) {
+	s := newTestServer()
+	s.Replay = testReplayGuard{}
+
+	c := connect(t, s)
+	reply, _, _ := c.login(testUsername, true)
+	assert.Equal(t, authproto.LoginSuccess, reply.Result)
+	c.conn.Close()
+	c.wait()
+
+	// The test client always uses the same public key
+	c = connect(t, s)
+	reply, _, _ = c.login(testUsername, true)
+	assert.Equal(t, authproto.LoginFailSuspended, reply.Result)
+	assert.ErrorIs(t, c.wait(), suspendedError{})
+}
+
+func TestUnexpectedPacket(t *testing.T) {
+	c := connect(t, newTestServer())
+	c.send(&authproto.RealmListRequest{})
+	assert.ErrorIs(t, c.wait(), ErrUnexpectedPacket)
+}
+
+func TestServeConnContext(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- newTestServer().ServeConn(ctx, serverConn)
+	}()
+
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("ServeConn did not return after the context was canceled")
+	}
+
+	_, err := clientConn.Read(make([]byte, 1))
+	assert.Error(t, err)
+}
+
+func TestServe(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("cannot listen on loopback:", err)
+	}
+
+	s := newTestServer()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Serve(ctx, l)
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	assert.NoError(t, err)
+	c := &testConn{t: t, conn: conn, server: s}
+	reply, _, _ := c.login(testUsername, true)
+	assert.Equal(t, authproto.LoginSuccess, reply.Result)
+
+	// Shutting down closes the open connection
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.True(t, errors.Is(err, context.Canceled))
+	case <-time.After(time.Second):
+		t.Fatal("Serve did not return after the context was canceled")
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	assert.Error(t, err)
+}
+
+// oneConnListener accepts conn, then fails.
+type oneConnListener struct {
+	conns chan net.Conn
+}
+
+var errAccept = errors.New("accept failed")
+
+func (l *oneConnListener) Accept() (net.Conn, error) {
+	if conn, ok := <-l.conns; ok {
+		return conn, nil
+	}
+	return nil, errAccept
+}
+
+func (l *oneConnListener) Close() error   { return nil }
+func (l *oneConnListener) Addr() net.Addr { return nil }
+
+func TestServeAcceptError(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+
+	l := &oneConnListener{conns: make(chan net.Conn, 1)}
+	l.conns <- serverConn
+	close(l.conns)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- newTestServer().Serve(context.Background(), l)
+	}()
+
+	// The open connection is closed instead of being waited for
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, errAccept)
+	case <-time.After(time.Second):
+		t.Fatal("Serve did not return after Accept failed")
+	}
+
+	_, err := clientConn.Read(make([]byte, 1))
+	assert.Error(t, err)
+}
@@ -0,0 +1,114 @@
+package authserver
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kangaroux/go-wow-srp6/builds"
+)
+
+var (
+	ErrAccountNotFound = errors.New("srp/authserver: account not found")
+	ErrSessionNotFound = errors.New("srp/authserver: session not found")
+)
+
+// Account is the information the server needs to authenticate a user.
+type Account struct {
+	// Username is the normalized (uppercase) username.
+	Username string
+
+	Salt     []byte
+	Verifier []byte
+
+	// SecurityFlags are the additional proofs enabled for the account. The server does not ask the
+	// client for them yet.
+	SecurityFlags builds.SecurityFlag
+
+	// Banned accounts are rejected with [authproto.LoginFailBanned].
+	Banned bool
+
+	// Accounts are rejected with [authproto.LoginFailSuspended] until SuspendedUntil.
+	SuspendedUntil time.Time
+}
+
+// Suspended returns whether the account is suspended at time now.
+func (a *Account) Suspended(now time.Time) bool {
+	return now.Before(a.SuspendedUntil)
+}
+
+// AccountStore looks up accounts by username.
+type AccountStore interface {
+	// Account returns the account with username. The username is already normalized. If the
+	// account does not exist, Account must return ErrAccountNotFound.
+	Account(ctx context.Context, username string) (*Account, error)
+}
+
+// SessionKeyStore receives the session key after a successful login. The world server uses the
+// session key to authenticate the client and encrypt headers, and the auth server uses it when the
+// client reconnects.
+type SessionKeyStore interface {
+	SaveSessionKey(ctx context.Context, username string, sessionKey []byte) error
+
+	// SessionKey returns the session key saved for username. If there is no session key,
+	// SessionKey must return ErrSessionNotFound.
+	SessionKey(ctx context.Context, username string) ([]byte, error)
+}
+
+// MemorySessionKeyStore is a [SessionKeyStore] which keeps session keys in memory. It is safe to
+// use concurrently.
+type MemorySessionKeyStore struct {
+	mu   sync.RWMutex
+	keys map[string][]byte
+}
+
+// NewMemorySessionKeyStore returns an empty session key store.
+func NewMemorySessionKeyStore() *MemorySessionKeyStore {
+	return &MemorySessionKeyStore{keys: make(map[string][]byte)}
+}
+
+func (s *MemorySessionKeyStore) SaveSessionKey(ctx context.Context, username string, sessionKey []byte) error {
+	key := make([]byte, len(sessionKey))
+	copy(key, sessionKey)
+
+	s.mu.Lock()
+	s.keys[strings.ToUpper(username)] = key
+	s.mu.Unlock()
+
+	return nil
+}
+
+func (s *MemorySessionKeyStore) SessionKey(ctx context.Context, username string) ([]byte, error) {
+	s.mu.RLock()
+	key, ok := s.keys[strings.ToUpper(username)]
+	s.mu.RUnlock()
+
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	return key, nil
+}
+
+// Limiter limits failed login attempts, to slow down brute force attacks. The ip is the client's
+// IP address, without the port.
+type Limiter interface {
+	// Allow is called before the server looks up the account. If the client must not log in,
+	// Allow returns an error. If the error has a LoginResult() method, the result is sent to the
+	// client, otherwise the client is sent [authproto.LoginFailDBBusy].
+	Allow(ctx context.Context, username, ip string) error
+
+	// Failed is called when the client sent an incorrect proof.
+	Failed(ctx context.Context, username, ip string) error
+
+	// Succeeded is called when the client logged in or reconnected.
+	Succeeded(ctx context.Context, username, ip string) error
+}
+
+// ReplayGuard rejects client data which has already been used by the account.
+type ReplayGuard interface {
+	// Check returns an error if username has already used data, otherwise it remembers it. If
+	// the error has a LoginResult() method, the result is sent to the client.
+	Check(ctx context.Context, username string, data ...[]byte) error
+}
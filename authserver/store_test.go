@@ -0,0 +1,24 @@
+package authserver
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemorySessionKeyStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemorySessionKeyStore()
+
+	_, err := s.SessionKey(ctx, "ALICE")
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+
+	key := []byte{1, 2, 3}
+	assert.NoError(t, s.SaveSessionKey(ctx, "alice", key))
+	key[0] = 0
+
+	saved, err := s.SessionKey(ctx, "ALICE")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{1, 2, 3}, saved)
+}
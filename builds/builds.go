@@ -0,0 +1,160 @@
+// Package builds lists the client builds known to this library and the protocol options each
+// of them needs.
+//
+// The auth and world protocols differ between expansions: the logon protocol version, the realm
+// list layout, the header algorithm and the supported security flags all depend on the client
+// build. Lookup returns everything needed to talk to a given build from one place.
+package builds
+
+import "fmt"
+
+// Expansion identifies the game expansion a build belongs to. The header algorithm used by the
+// world server is determined by the expansion.
+type Expansion uint8
+
+const (
+	Vanilla Expansion = iota
+	TBC
+	Wrath
+)
+
+func (e Expansion) String() string {
+	switch e {
+	case Vanilla:
+		return "Vanilla"
+	case TBC:
+		return "TBC"
+	case Wrath:
+		return "Wrath"
+	default:
+		return fmt.Sprintf("Expansion(%d)", uint8(e))
+	}
+}
+
+// SecurityFlag is a bit flag sent in the logon challenge reply which asks the client for an
+// additional proof, such as a PIN.
+type SecurityFlag uint8
+
+const (
+	SecurityFlagPIN           SecurityFlag = 0x01
+	SecurityFlagMatrixCard    SecurityFlag = 0x02
+	SecurityFlagAuthenticator SecurityFlag = 0x04
+
+	SecurityFlagNone SecurityFlag = 0
+)
+
+// Logon protocol versions sent by the client in the logon challenge.
+const (
+	ProtocolVersionVanilla = 3
+	ProtocolVersionTBC     = 8
+	ProtocolVersionWrath   = 8
+)
+
+// Build describes a single client build.
+type Build struct {
+	// Number is the build number sent by the client, e.g. 12340.
+	Number uint16
+
+	Major uint8
+	Minor uint8
+	Patch uint8
+
+	// Suffix is the letter appended to some versions, e.g. "a" for 3.3.5a.
+	Suffix string
+
+	Expansion Expansion
+
+	// ProtocolVersion is the logon protocol version the client sends in the logon challenge.
+	// It determines the layout of the logon proof and realm list packets.
+	ProtocolVersion uint8
+
+	// SecurityFlags is the set of security flags the client understands.
+	SecurityFlags SecurityFlag
+}
+
+// Version returns the human readable version, e.g. "3.3.5a".
+func (b Build) Version() string {
+	return fmt.Sprintf("%d.%d.%d%s", b.Major, b.Minor, b.Patch, b.Suffix)
+}
+
+func (b Build) String() string {
+	return fmt.Sprintf("%s (%d)", b.Version(), b.Number)
+}
+
+// Supports returns true if the client understands every flag in flags.
+func (b Build) Supports(flags SecurityFlag) bool {
+	return b.SecurityFlags&flags == flags
+}
+
+var known = []Build{
+	{
+		Number:          5875,
+		Major:           1,
+		Minor:           12,
+		Patch:           1,
+		Expansion:       Vanilla,
+		ProtocolVersion: ProtocolVersionVanilla,
+		SecurityFlags:   SecurityFlagPIN,
+	},
+	{
+		Number:          6005,
+		Major:           1,
+		Minor:           12,
+		Patch:           2,
+		Expansion:       Vanilla,
+		ProtocolVersion: ProtocolVersionVanilla,
+		SecurityFlags:   SecurityFlagPIN,
+	},
+	{
+		Number:          6141,
+		Major:           1,
+		Minor:           12,
+		Patch:           3,
+		Expansion:       Vanilla,
+		ProtocolVersion: ProtocolVersionVanilla,
+		SecurityFlags:   SecurityFlagPIN,
+	},
+	{
+		Number:          8606,
+		Major:           2,
+		Minor:           4,
+		Patch:           3,
+		Expansion:       TBC,
+		ProtocolVersion: ProtocolVersionTBC,
+		SecurityFlags:   SecurityFlagPIN | SecurityFlagMatrixCard | SecurityFlagAuthenticator,
+	},
+	{
+		Number:          12340,
+		Major:           3,
+		Minor:           3,
+		Patch:           5,
+		Suffix:          "a",
+		Expansion:       Wrath,
+		ProtocolVersion: ProtocolVersionWrath,
+		SecurityFlags:   SecurityFlagPIN | SecurityFlagMatrixCard | SecurityFlagAuthenticator,
+	},
+}
+
+// Lookup returns the build with the given build number. If the build is not known, Lookup
+// returns false.
+func Lookup(number uint16) (Build, bool) {
+	for _, b := range known {
+		if b.Number == number {
+			return b, true
+		}
+	}
+	return Build{}, false
+}
+
+// IsSupported returns true if the build number is known.
+func IsSupported(number uint16) bool {
+	_, ok := Lookup(number)
+	return ok
+}
+
+// All returns a copy of every known build, ordered by build number.
+func All() []Build {
+	ret := make([]Build, len(known))
+	copy(ret, known)
+	return ret
+}
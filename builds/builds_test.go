@@ -0,0 +1,53 @@
+package builds
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookup(t *testing.T) {
+	t.Run("known builds", func(t *testing.T) {
+		cases := []struct {
+			number    uint16
+			version   string
+			expansion Expansion
+			protocol  uint8
+		}{
+			{5875, "1.12.1", Vanilla, 3},
+			{8606, "2.4.3", TBC, 8},
+			{12340, "3.3.5a", Wrath, 8},
+		}
+
+		for _, c := range cases {
+			b, ok := Lookup(c.number)
+			assert.True(t, ok)
+			assert.Equal(t, c.version, b.Version())
+			assert.Equal(t, c.expansion, b.Expansion)
+			assert.Equal(t, c.protocol, b.ProtocolVersion)
+			assert.True(t, IsSupported(c.number))
+		}
+	})
+
+	t.Run("unknown build", func(t *testing.T) {
+		_, ok := Lookup(1234)
+		assert.False(t, ok)
+		assert.False(t, IsSupported(1234))
+	})
+}
+
+func TestSupports(t *testing.T) {
+	vanilla, _ := Lookup(5875)
+	wrath, _ := Lookup(12340)
+
+	assert.True(t, vanilla.Supports(SecurityFlagNone))
+	assert.True(t, vanilla.Supports(SecurityFlagPIN))
+	assert.False(t, vanilla.Supports(SecurityFlagPIN|SecurityFlagAuthenticator))
+	assert.True(t, wrath.Supports(SecurityFlagPIN|SecurityFlagMatrixCard|SecurityFlagAuthenticator))
+}
+
+func TestAllReturnsCopy(t *testing.T) {
+	all := All()
+	all[0].Number = 0
+	assert.True(t, IsSupported(5875))
+}
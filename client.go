@@ -0,0 +1,42 @@
+package srp
+
+import "math/big"
+
+// ClientPublicKey returns a 32 byte public key. The private key should be a 32 byte array
+// cryptographically secure random data ([crypto/rand]). The client should send the public key
+// to the server in plaintext.
+func ClientPublicKey(clientPrivateKey []byte) []byte {
+	return intToBytes(KeySize, big.NewInt(0).Exp(g, bytesToInt(clientPrivateKey), n))
+}
+
+// ClientSessionKey returns the 40 byte session key computed by the client. It is the same key
+// the server computes with [SessionKey] if the client knows the password.
+// The session key should never be made public.
+func ClientSessionKey(
+	username,
+	password string,
+	salt,
+	clientPublicKey,
+	serverPublicKey,
+	clientPrivateKey []byte,
+) []byte {
+	x := bytesToInt(calculateX(username, password, salt))
+	u := bytesToInt(calculateU(clientPublicKey, serverPublicKey))
+	S := calculateClientSKey(x, u, serverPublicKey, clientPrivateKey)
+	return calculateInterleave(S)
+}
+
+// calculateClientSKey returns the intermediate 32 byte key used to generate the session key.
+// S = (B - k * g^x)^(a + u * x) % N
+func calculateClientSKey(x, u *big.Int, serverPublicKey, clientPrivateKey []byte) []byte {
+	kgx := big.NewInt(0).Exp(g, x, n)
+	kgx.Mul(kgx, k)
+
+	base := big.NewInt(0).Sub(bytesToInt(serverPublicKey), kgx)
+	base.Mod(base, n)
+
+	exp := big.NewInt(0).Mul(u, x)
+	exp.Add(exp, bytesToInt(clientPrivateKey))
+
+	return intToBytes(KeySize, base.Exp(base, exp, n))
+}
@@ -0,0 +1,37 @@
+package srp
+
+import (
+	"testing"
+
+	"github.com/kangaroux/go-wow-srp6/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientPublicKey(t *testing.T) {
+	// The client public key is computed the same way as the server public key, without k*v
+	privateKey := internal.MustDecodeHex("A47F5CBCC9B5C7E8B8F0D0AC2E0D99AD4C7D25E4F2F1C15E1C7B4F8D1D9E5B31")
+	assert.Equal(t, ServerPublicKey(make([]byte, VerifierSize), privateKey), ClientPublicKey(privateKey))
+}
+
+func TestClientSessionKey(t *testing.T) {
+	rows := internal.MustLoadTestData("testdata/srp/calculate_verifier.csv")
+
+	for i, row := range rows {
+		username := row[0]
+		password := row[1]
+		salt := internal.MustDecodeHex(row[2])
+		verifier := internal.MustDecodeHex(row[3])
+
+		// Reuse the salts from other rows as the private keys
+		clientPrivate := internal.MustDecodeHex(rows[(i+1)%len(rows)][2])
+		serverPrivate := internal.MustDecodeHex(rows[(i+2)%len(rows)][2])
+
+		clientPublic := ClientPublicKey(clientPrivate)
+		serverPublic := ServerPublicKey(verifier, serverPrivate)
+
+		assert.Equal(t,
+			SessionKey(clientPublic, serverPublic, serverPrivate, verifier),
+			ClientSessionKey(username, password, salt, clientPublic, serverPublic, clientPrivate),
+		)
+	}
+}
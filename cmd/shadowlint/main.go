@@ -0,0 +1,52 @@
+// Command shadowlint checks shadow credential files for problems.
+//
+// Usage:
+//
+//	shadowlint FILE...
+//
+// Each problem is printed as "file:line: message". The exit status is 1 if any problems were
+// found, and 2 if a file couldn't be read.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/kangaroux/go-wow-srp6/shadow"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: shadowlint FILE...")
+		os.Exit(2)
+	}
+
+	status := 0
+	for _, path := range os.Args[1:] {
+		n, err := lint(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "shadowlint: %s\n", err)
+			status = 2
+		} else if n > 0 && status == 0 {
+			status = 1
+		}
+	}
+
+	os.Exit(status)
+}
+
+// lint prints the problems in the file at path and returns how many there were.
+func lint(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	problems, err := shadow.Lint(f)
+	for _, p := range problems {
+		fmt.Printf("%s:%d: %s\n", path, p.Line, p.Err)
+	}
+
+	return len(problems), err
+}
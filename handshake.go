@@ -0,0 +1,112 @@
+package srp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"strings"
+)
+
+// UnknownAccountSecretSize is the recommended size of the secret passed to
+// [NewUnknownHandshake].
+const UnknownAccountSecretSize = 32
+
+// Handshake is the server side of a single login. It does the same work for accounts which exist
+// and for accounts which don't, so the server's response time doesn't reveal whether an account
+// exists.
+//
+// Use [NewHandshake] for accounts which exist and [NewUnknownHandshake] for accounts which don't,
+// then send the salt and [Handshake.ServerPublicKey] in the challenge reply, and check the
+// client's proof with [Handshake.Verify].
+type Handshake struct {
+	username         string
+	salt             []byte
+	verifier         []byte
+	serverPrivateKey []byte
+	serverPublicKey  []byte
+	unknown          bool
+}
+
+// NewHandshake starts a login for an account which exists. The private key should be a 32 byte
+// array of cryptographically secure random data ([crypto/rand]).
+func NewHandshake(username string, salt, verifier, serverPrivateKey []byte) *Handshake {
+	return &Handshake{
+		username:         strings.ToUpper(username),
+		salt:             salt,
+		verifier:         verifier,
+		serverPrivateKey: serverPrivateKey,
+		serverPublicKey:  ServerPublicKey(verifier, serverPrivateKey),
+	}
+}
+
+// NewUnknownHandshake starts a login for an account which doesn't exist. The salt and verifier
+// are derived from the secret and username, so the client is sent the same salt each time it
+// tries the username, like a real account. The secret should be [UnknownAccountSecretSize] bytes
+// of cryptographically secure random data, which the server keeps for its lifetime.
+//
+// [Handshake.Verify] always fails for an unknown account.
+func NewUnknownHandshake(username string, secret, serverPrivateKey []byte) *Handshake {
+	username = strings.ToUpper(username)
+
+	// The verifier is reduced mod N, so it has the same size as a real verifier and costs the
+	// same to use. Real verifiers are g^x mod N, which the fake verifier can't be told apart from.
+	v := bytesToInt(unknownAccountHMAC(secret, "verifier", username))
+
+	h := NewHandshake(
+		username,
+		unknownAccountHMAC(secret, "salt", username),
+		intToBytes(VerifierSize, v.Mod(v, n)),
+		serverPrivateKey,
+	)
+	h.unknown = true
+	return h
+}
+
+// Salt returns the salt to send to the client.
+func (h *Handshake) Salt() []byte {
+	return h.salt
+}
+
+// ServerPublicKey returns the public key to send to the client.
+func (h *Handshake) ServerPublicKey() []byte {
+	return h.serverPublicKey
+}
+
+// Verify checks the client's public key and proof. On success, it returns the session key and the
+// server proof to send to the client. If the public key is invalid, Verify returns
+// ErrInvalidPublicKey. If the proof doesn't match or the account doesn't exist, Verify returns
+// ErrInvalidClientProof.
+func (h *Handshake) Verify(clientPublicKey, clientProof []byte) (sessionKey, serverProof []byte, err error) {
+	if err := VerifyClientPublicKey(clientPublicKey); err != nil {
+		return nil, nil, err
+	}
+
+	sessionKey = SessionKey(clientPublicKey, h.serverPublicKey, h.serverPrivateKey, h.verifier)
+	expected := ClientChallengeProof(h.username, h.salt, clientPublicKey, h.serverPublicKey, sessionKey)
+	serverProof = ServerChallengeProof(clientPublicKey, clientProof, sessionKey)
+
+	// Both checks are always done, so a matching proof for an unknown account takes the same time
+	match := subtle.ConstantTimeCompare(expected, clientProof) & subtle.ConstantTimeEq(boolToInt(h.unknown), 0)
+	if match != 1 {
+		return nil, nil, ErrInvalidClientProof
+	}
+
+	return sessionKey, serverProof, nil
+}
+
+// unknownAccountHMAC returns a 32 byte value derived from the secret, label and username. The
+// size matches a salt and a verifier.
+func unknownAccountHMAC(secret []byte, label, username string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(label))
+	mac.Write([]byte{0})
+	mac.Write([]byte(username))
+	return mac.Sum(nil)
+}
+
+func boolToInt(b bool) int32 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,156 @@
+package srp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"math"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testSecret    = bytes.Repeat([]byte{0x33}, UnknownAccountSecretSize)
+	testSalt      = bytes.Repeat([]byte{0x5A}, SaltSize)
+	testServerKey = bytes.Repeat([]byte{0x42}, KeySize)
+	testClientKey = bytes.Repeat([]byte{0x24}, KeySize)
+	testVerifier  = PasswordVerifier("ALICE", "PASSWORD", testSalt)
+)
+
+// clientProof returns the client's public key and proof for a login to h with password.
+func clientProof(h *Handshake, username, password string) ([]byte, []byte) {
+	A := ClientPublicKey(testClientKey)
+	K := ClientSessionKey(username, password, h.Salt(), A, h.ServerPublicKey(), testClientKey)
+	return A, ClientChallengeProof(username, h.Salt(), A, h.ServerPublicKey(), K)
+}
+
+func TestHandshake(t *testing.T) {
+	h := NewHandshake("alice", testSalt, testVerifier, testServerKey)
+	assert.Equal(t, testSalt, h.Salt())
+	assert.Equal(t, ServerPublicKey(testVerifier, testServerKey), h.ServerPublicKey())
+
+	A, M1 := clientProof(h, "ALICE", "PASSWORD")
+	sessionKey, serverProof, err := h.Verify(A, M1)
+	assert.NoError(t, err)
+	assert.Equal(t, SessionKey(A, h.ServerPublicKey(), testServerKey, testVerifier), sessionKey)
+	assert.Equal(t, ServerChallengeProof(A, M1, sessionKey), serverProof)
+
+	A, M1 = clientProof(h, "ALICE", "WRONG")
+	_, _, err = h.Verify(A, M1)
+	assert.ErrorIs(t, err, ErrInvalidClientProof)
+
+	_, _, err = h.Verify(make([]byte, KeySize), M1)
+	assert.ErrorIs(t, err, ErrInvalidPublicKey)
+}
+
+func TestUnknownHandshake(t *testing.T) {
+	h := NewUnknownHandshake("bob", testSecret, testServerKey)
+	assert.Len(t, h.Salt(), SaltSize)
+	assert.Len(t, h.ServerPublicKey(), KeySize)
+
+	// The salt is the same every time, and doesn't depend on the username's case
+	again := NewUnknownHandshake("BOB", testSecret, testServerKey)
+	assert.Equal(t, h.Salt(), again.Salt())
+	assert.Equal(t, h.ServerPublicKey(), again.ServerPublicKey())
+
+	// Different usernames and secrets give different salts
+	assert.NotEqual(t, h.Salt(), NewUnknownHandshake("carol", testSecret, testServerKey).Salt())
+	assert.NotEqual(t, h.Salt(), NewUnknownHandshake("bob", testSalt, testServerKey).Salt())
+
+	// The fake verifier is reduced mod N like a real verifier
+	assert.Equal(t, -1, bytesToInt(h.verifier).Cmp(n))
+
+	A, M1 := clientProof(h, "BOB", "PASSWORD")
+	_, _, err := h.Verify(A, M1)
+	assert.ErrorIs(t, err, ErrInvalidClientProof)
+
+	// Even a proof computed with the fake verifier fails
+	sessionKey := SessionKey(A, h.ServerPublicKey(), testServerKey, h.verifier)
+	M1 = ClientChallengeProof("BOB", h.Salt(), A, h.ServerPublicKey(), sessionKey)
+	_, _, err = h.Verify(A, M1)
+	assert.ErrorIs(t, err, ErrInvalidClientProof)
+}
+
+// TestUnknownHandshakeSameWork checks that an unknown account does the same work as an account
+// which exists: it's a real handshake with a derived salt and verifier, and only the final check
+// differs.
+func TestUnknownHandshakeSameWork(t *testing.T) {
+	h := NewUnknownHandshake("bob", testSecret, testServerKey)
+	known := NewHandshake("BOB", h.salt, h.verifier, testServerKey)
+
+	assert.Len(t, h.verifier, VerifierSize)
+	assert.Equal(t, known.ServerPublicKey(), h.ServerPublicKey())
+
+	// A proof which matches the derived verifier is accepted by the known handshake, so the
+	// unknown handshake computed the same session key before rejecting it
+	A := ClientPublicKey(testClientKey)
+	sessionKey := SessionKey(A, h.ServerPublicKey(), testServerKey, h.verifier)
+	M1 := ClientChallengeProof("BOB", h.Salt(), A, h.ServerPublicKey(), sessionKey)
+
+	knownKey, _, err := known.Verify(A, M1)
+	assert.NoError(t, err)
+	assert.Equal(t, sessionKey, knownKey)
+
+	_, _, err = h.Verify(A, M1)
+	assert.ErrorIs(t, err, ErrInvalidClientProof)
+}
+
+// TestHandshakeTiming checks that a login for an unknown account takes as long as a failed login
+// for an account which exists. Samples are interleaved so changes in load affect both equally, and
+// the medians are compared so outliers from GC or scheduling don't matter.
+//
+// Wall clock times are unreliable on shared machines, so the test only runs when SRP_TIMING_TEST
+// is set. [TestUnknownHandshakeSameWork] covers the same property without timing.
+func TestHandshakeTiming(t *testing.T) {
+	if testing.Short() || os.Getenv("SRP_TIMING_TEST") == "" {
+		t.Skip("set SRP_TIMING_TEST=1 to run the timing test")
+	}
+
+	const samples = 400
+	const tolerance = 0.15
+
+	randomKey := func() []byte {
+		key := make([]byte, KeySize)
+		rand.Read(key)
+		return key
+	}
+
+	A := ClientPublicKey(testClientKey)
+	M1 := bytes.Repeat([]byte{0x01}, ProofSize)
+
+	measure := func(unknown bool) time.Duration {
+		serverKey := randomKey()
+		start := time.Now()
+
+		var h *Handshake
+		if unknown {
+			h = NewUnknownHandshake("BOB", testSecret, serverKey)
+		} else {
+			h = NewHandshake("ALICE", testSalt, testVerifier, serverKey)
+		}
+		h.Verify(A, M1)
+
+		return time.Since(start)
+	}
+
+	known := make([]time.Duration, 0, samples)
+	unknown := make([]time.Duration, 0, samples)
+	for i := 0; i < samples; i++ {
+		known = append(known, measure(false))
+		unknown = append(unknown, measure(true))
+	}
+
+	knownMedian, unknownMedian := median(known), median(unknown)
+	ratio := math.Abs(float64(knownMedian-unknownMedian)) / float64(knownMedian)
+
+	t.Logf("known median %s, unknown median %s, difference %.1f%%", knownMedian, unknownMedian, ratio*100)
+	assert.Less(t, ratio, tolerance)
+}
+
+func median(d []time.Duration) time.Duration {
+	sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+	return d[len(d)/2]
+}
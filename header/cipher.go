@@ -0,0 +1,166 @@
+package header
+
+import (
+	"errors"
+
+	"github.com/kangaroux/go-wow-srp6/builds"
+)
+
+// ClientHeaderSize is the size of a client header in every expansion: a 2 byte size and a 4 byte
+// opcode.
+const ClientHeaderSize = smallClientHeaderSize
+
+// DefaultMaxClientSize is the default limit for the body size of client packets.
+const DefaultMaxClientSize = 10240
+
+// The opcode is included in the size field of client headers.
+const clientOpcodeSize = 4
+
+var (
+	ErrUnsupportedBuild    = errors.New("srp/header: unsupported client build")
+	ErrHeaderSizeTooSmall  = errors.New("srp/header: header size is too small")
+	ErrInvalidClientHeader = errors.New("srp/header: client header must be 6 bytes")
+)
+
+// HeaderCipher is implemented by the header of each expansion. It lets a world server pick the
+// header at runtime with [NewCipher].
+type HeaderCipher interface {
+	// Init sets up the ciphers using sessionKey.
+	Init(sessionKey []byte) error
+
+	// Encode returns a server header with opcode and size, encrypted if Init was called. The size
+	// must not include the opcode. Headers must be written in the order they were encoded, so
+	// sending from several goroutines needs a lock around Encode and the write, such as the one in
+	// worldconn.Writer.
+	Encode(opcode uint16, size uint32) ([]byte, error)
+
+	// AppendEncode is like Encode, but appends the header to dst and returns the extended slice.
+	AppendEncode(dst []byte, opcode uint16, size uint32) ([]byte, error)
+
+	// EncryptServer encrypts a server header in-place.
+	EncryptServer(data []byte) error
+
+	// DecryptClient decrypts a client header in-place.
+	DecryptClient(data []byte) error
+
+	// DecodeClientHeader decrypts a client header in-place if Init was called, and returns the
+	// opcode and the size of the body.
+	DecodeClientHeader(data []byte) (opcode uint32, size uint32, err error)
+
+	// ClientHeaderSize returns the size of a client header.
+	ClientHeaderSize() int
+
+	// ServerHeaderSize returns the size of the server header for a packet with size, not
+	// including the opcode.
+	ServerHeaderSize(size uint32) int
+}
+
+var (
+	_ HeaderCipher = (*VanillaHeader)(nil)
+	_ HeaderCipher = (*TBCHeader)(nil)
+	_ HeaderCipher = (*WrathHeader)(nil)
+)
+
+// NewCipher returns the header for the client build. If the build is not known, NewCipher returns
+// ErrUnsupportedBuild.
+func NewCipher(build uint16) (HeaderCipher, error) {
+	b, ok := builds.Lookup(build)
+	if !ok {
+		return nil, ErrUnsupportedBuild
+	}
+
+	switch b.Expansion {
+	case builds.Vanilla:
+		return &VanillaHeader{}, nil
+	case builds.TBC:
+		return &TBCHeader{}, nil
+	case builds.Wrath:
+		return &WrathHeader{}, nil
+	default:
+		return nil, ErrUnsupportedBuild
+	}
+}
+
+// DecodeClientHeader decrypts a client header in-place if [VanillaHeader.Init] was called, and returns
+// the opcode and the size of the body, not including the opcode. If the size is smaller than the
+// opcode, DecodeClientHeader returns ErrHeaderSizeTooSmall, and if the body is larger than
+// MaxClientSize it returns ErrHeaderSizeTooLarge.
+func (h *VanillaHeader) DecodeClientHeader(data []byte) (opcode uint32, size uint32, err error) {
+	if len(data) != ClientHeaderSize {
+		return 0, 0, ErrInvalidClientHeader
+	}
+	h.decryptIfInit(data)
+	return decodeClientHeader(data, h.MaxClientSize)
+}
+
+// DecodeClientHeader decrypts a client header in-place if [TBCHeader.Init] was called, and returns
+// the opcode and the size of the body, not including the opcode. If the size is smaller than the
+// opcode, DecodeClientHeader returns ErrHeaderSizeTooSmall, and if the body is larger than
+// MaxClientSize it returns ErrHeaderSizeTooLarge.
+func (h *TBCHeader) DecodeClientHeader(data []byte) (opcode uint32, size uint32, err error) {
+	if len(data) != ClientHeaderSize {
+		return 0, 0, ErrInvalidClientHeader
+	}
+	h.decryptIfInit(data)
+	return decodeClientHeader(data, h.MaxClientSize)
+}
+
+// EncryptServer encrypts a server header in-place. It is the same as [WrathHeader.Encrypt].
+func (h *WrathHeader) EncryptServer(data []byte) error {
+	return h.Encrypt(data)
+}
+
+// DecryptClient decrypts a client header in-place. It is the same as [WrathHeader.Decrypt].
+func (h *WrathHeader) DecryptClient(data []byte) error {
+	return h.Decrypt(data)
+}
+
+// DecodeClientHeader decrypts a client header in-place if [WrathHeader.Init] was called, and returns
+// the opcode and the size of the body, not including the opcode. If the size is smaller than the
+// opcode, DecodeClientHeader returns ErrHeaderSizeTooSmall, and if the body is larger than
+// MaxClientSize it returns ErrHeaderSizeTooLarge.
+func (h *WrathHeader) DecodeClientHeader(data []byte) (opcode uint32, size uint32, err error) {
+	if len(data) != ClientHeaderSize {
+		return 0, 0, ErrInvalidClientHeader
+	}
+	h.decryptIfInit(data)
+	return decodeClientHeader(data, h.MaxClientSize)
+}
+
+// ClientHeaderSize returns the size of a client header, which is always 6 bytes.
+func (h *WrathHeader) ClientHeaderSize() int {
+	return ClientHeaderSize
+}
+
+// ServerHeaderSize returns the size of a server header, which is 5 bytes for large packets and 4
+// bytes otherwise.
+func (h *WrathHeader) ServerHeaderSize(size uint32) int {
+	if size+2 > largeHeaderThreshold {
+		return 5
+	}
+	return 4
+}
+
+// decodeClientHeader returns the opcode and body size of a decrypted client header.
+//
+// The header format is: <size><opcode>
+// <size> is 2 bytes big endian, and includes the opcode
+// <opcode> is 4 bytes little endian
+func decodeClientHeader(data []byte, maxSize uint32) (opcode uint32, size uint32, err error) {
+	size = uint32(data[0])<<8 | uint32(data[1])
+	opcode = uint32(data[2]) | uint32(data[3])<<8 | uint32(data[4])<<16 | uint32(data[5])<<24
+
+	if size < clientOpcodeSize {
+		return 0, 0, ErrHeaderSizeTooSmall
+	}
+	size -= clientOpcodeSize
+
+	if maxSize == 0 {
+		maxSize = DefaultMaxClientSize
+	}
+	if size > maxSize {
+		return 0, 0, ErrHeaderSizeTooLarge
+	}
+
+	return opcode, size, nil
+}
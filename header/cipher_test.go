@@ -0,0 +1,164 @@
+package header
+
+import (
+	"testing"
+
+	"github.com/kangaroux/go-wow-srp6/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCipher(t *testing.T) {
+	tests := []struct {
+		build    uint16
+		expected HeaderCipher
+	}{
+		{5875, &VanillaHeader{}},
+		{6005, &VanillaHeader{}},
+		{8606, &TBCHeader{}},
+		{12340, &WrathHeader{}},
+	}
+
+	for _, tt := range tests {
+		h, err := NewCipher(tt.build)
+		assert.NoError(t, err, tt.build)
+		assert.IsType(t, tt.expected, h, tt.build)
+	}
+
+	_, err := NewCipher(1234)
+	assert.ErrorIs(t, err, ErrUnsupportedBuild)
+}
+
+func TestHeaderCipher(t *testing.T) {
+	sessionKey := internal.MustDecodeHex("403FCE7B2B1FCDAE43F118B6C7E517D5A1498088180936A3E45B9888978B7675ECBAA7DB4CA4E8DE")
+
+	for _, build := range []uint16{5875, 8606, 12340} {
+		h, _ := NewCipher(build)
+		assert.Equal(t, 6, h.ClientHeaderSize())
+
+		header, err := h.Encode(0x1EE, 10)
+		assert.NoError(t, err)
+		assert.Len(t, header, h.ServerHeaderSize(10))
+
+		// EncryptServer and DecryptClient use the same ciphers as Encrypt and Decrypt
+		assert.NoError(t, h.Init(sessionKey))
+		other, _ := NewCipher(build)
+		assert.NoError(t, other.Init(sessionKey))
+
+		data := []byte{1, 2, 3, 4, 5, 6}
+		expected := append([]byte(nil), data...)
+		assert.NoError(t, h.EncryptServer(data))
+		assert.NoError(t, other.(interface{ Encrypt([]byte) error }).Encrypt(expected))
+		assert.Equal(t, expected, data, build)
+
+		assert.NoError(t, h.DecryptClient(data))
+		assert.NoError(t, other.(interface{ Decrypt([]byte) error }).Decrypt(expected))
+		assert.Equal(t, expected, data, build)
+	}
+}
+
+func TestWrathServerHeaderSize(t *testing.T) {
+	h := &WrathHeader{}
+	for _, size := range []uint32{0, 100, largeHeaderThreshold - 2, largeHeaderThreshold - 1, 0x10000} {
+		header, err := h.Encode(0x1EE, size)
+		assert.NoError(t, err)
+		assert.Len(t, header, h.ServerHeaderSize(size), size)
+	}
+}
+
+// encryptClientHeader encrypts data the way the client would for h.
+func encryptClientHeader(h HeaderCipher, sessionKey, data []byte) {
+	switch h.(type) {
+	case *VanillaHeader:
+		newRollingCipher(sessionKey).encrypt(data)
+	case *TBCHeader:
+		newRollingCipher(generateKey(sessionKey, tbcSeed)).encrypt(data)
+	case *WrathHeader:
+		c, _ := NewRC4(generateKey(sessionKey, wrathDecryptKey))
+		drop1024(c)
+		c.XORKeyStream(data, data)
+	}
+}
+
+func TestDecodeClientHeader(t *testing.T) {
+	sessionKey := internal.MustDecodeHex("403FCE7B2B1FCDAE43F118B6C7E517D5A1498088180936A3E45B9888978B7675ECBAA7DB4CA4E8DE")
+
+	for _, build := range []uint16{5875, 8606, 12340} {
+		h, _ := NewCipher(build)
+
+		// Before Init, the header isn't decrypted
+		opcode, size, err := h.DecodeClientHeader([]byte{0x00, 0x0E, 0xED, 0x01, 0x00, 0x00})
+		assert.NoError(t, err, build)
+		assert.Equal(t, uint32(0x1ED), opcode, build)
+		assert.Equal(t, uint32(10), size, build)
+
+		assert.NoError(t, h.Init(sessionKey))
+		data := []byte{0x01, 0x04, 0x78, 0x56, 0x34, 0x12}
+		encryptClientHeader(h, sessionKey, data)
+
+		opcode, size, err = h.DecodeClientHeader(data)
+		assert.NoError(t, err, build)
+		assert.Equal(t, uint32(0x12345678), opcode, build)
+		assert.Equal(t, uint32(0x100), size, build)
+		assert.Equal(t, []byte{0x01, 0x04, 0x78, 0x56, 0x34, 0x12}, data, "decrypted in-place")
+	}
+}
+
+func TestDecodeClientHeaderInvalid(t *testing.T) {
+	h := &WrathHeader{}
+
+	_, _, err := h.DecodeClientHeader([]byte{0x00, 0x03, 0x00, 0x00, 0x00, 0x00})
+	assert.ErrorIs(t, err, ErrHeaderSizeTooSmall)
+
+	_, _, err = h.DecodeClientHeader([]byte{0x00, 0x04, 0x00, 0x00})
+	assert.ErrorIs(t, err, ErrInvalidClientHeader)
+
+	// An empty body is allowed
+	_, size, err := h.DecodeClientHeader([]byte{0x00, 0x04, 0x00, 0x00, 0x00, 0x00})
+	assert.NoError(t, err)
+	assert.Zero(t, size)
+
+	_, size, err = h.DecodeClientHeader([]byte{0x28, 0x04, 0x00, 0x00, 0x00, 0x00})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(DefaultMaxClientSize), size)
+
+	_, _, err = h.DecodeClientHeader([]byte{0x28, 0x05, 0x00, 0x00, 0x00, 0x00})
+	assert.ErrorIs(t, err, ErrHeaderSizeTooLarge)
+
+	h.MaxClientSize = 100
+	_, _, err = h.DecodeClientHeader([]byte{0x00, 0x69, 0x00, 0x00, 0x00, 0x00})
+	assert.ErrorIs(t, err, ErrHeaderSizeTooLarge)
+	_, size, err = h.DecodeClientHeader([]byte{0x00, 0x68, 0x00, 0x00, 0x00, 0x00})
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(100), size)
+}
+
+func TestAppendEncode(t *testing.T) {
+	for _, build := range []uint16{5875, 8606, 12340} {
+		h, _ := NewCipher(build)
+		other, _ := NewCipher(build)
+		assert.NoError(t, h.Init(testSessionKey))
+		assert.NoError(t, other.Init(testSessionKey))
+
+		for _, size := range []uint32{10, 0x7FFE} {
+			expected, err := other.Encode(0x1EE, size)
+			assert.NoError(t, err)
+
+			buf, err := h.AppendEncode([]byte{0xFF}, 0x1EE, size)
+			assert.NoError(t, err)
+			assert.Equal(t, append([]byte{0xFF}, expected...), buf, build)
+		}
+
+		_, err := h.AppendEncode(nil, 0x1EE, 0x800000)
+		assert.ErrorIs(t, err, ErrHeaderSizeTooLarge)
+	}
+
+	c := &WrathClientHeader{}
+	other := &WrathClientHeader{}
+	assert.NoError(t, c.Init(testSessionKey))
+	assert.NoError(t, other.Init(testSessionKey))
+
+	expected, _ := other.Encode(0x1ED, 10)
+	buf, err := c.AppendEncode([]byte{0xFF}, 0x1ED, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, append([]byte{0xFF}, expected...), buf)
+}
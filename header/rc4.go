@@ -0,0 +1,109 @@
+package header
+
+import "errors"
+
+// rc4StateSize is the size of a marshaled RC4 state: the 256 byte permutation, then i and j.
+const rc4StateSize = 256 + 2
+
+var (
+	ErrInvalidRC4Key   = errors.New("srp/header: RC4 key must be 1 to 256 bytes")
+	ErrInvalidRC4State = errors.New("srp/header: invalid RC4 state")
+)
+
+// RC4 is the RC4 stream cipher used by [WrathHeader]. Unlike crypto/rc4, its state can be copied
+// with Clone and saved with MarshalBinary. [WrathHeader.MarshalBinary] uses it to checkpoint a
+// session's header ciphers.
+//
+// RC4 is not safe to use concurrently.
+type RC4 struct {
+	s    [256]byte
+	i, j byte
+}
+
+// NewRC4 returns an RC4 cipher for key. The key must be 1 to 256 bytes.
+func NewRC4(key []byte) (*RC4, error) {
+	if len(key) < 1 || len(key) > 256 {
+		return nil, ErrInvalidRC4Key
+	}
+
+	c := &RC4{}
+	for i := range c.s {
+		c.s[i] = byte(i)
+	}
+
+	var j byte
+	for i := range c.s {
+		j += c.s[i] + key[i%len(key)]
+		c.s[i], c.s[j] = c.s[j], c.s[i]
+	}
+
+	return c, nil
+}
+
+// XORKeyStream sets dst to src XORed with the keystream. dst and src may be the same slice. If dst
+// is shorter than src, XORKeyStream panics.
+func (c *RC4) XORKeyStream(dst, src []byte) {
+	if len(dst) < len(src) {
+		panic("srp/header: RC4 output is smaller than input")
+	}
+
+	i, j := c.i, c.j
+	for k, b := range src {
+		i++
+		j += c.s[i]
+		c.s[i], c.s[j] = c.s[j], c.s[i]
+		dst[k] = b ^ c.s[c.s[i]+c.s[j]]
+	}
+	c.i, c.j = i, j
+}
+
+// Discard skips the next n bytes of the keystream.
+func (c *RC4) Discard(n int) {
+	i, j := c.i, c.j
+	for k := 0; k < n; k++ {
+		i++
+		j += c.s[i]
+		c.s[i], c.s[j] = c.s[j], c.s[i]
+	}
+	c.i, c.j = i, j
+}
+
+// Clone returns a copy of c. The copy continues the keystream from the same position, independent
+// of c.
+func (c *RC4) Clone() *RC4 {
+	clone := *c
+	return &clone
+}
+
+// MarshalBinary returns the state of c. The state includes the key, so it must be kept as secret as
+// the session key.
+func (c *RC4) MarshalBinary() ([]byte, error) {
+	return c.appendState(make([]byte, 0, rc4StateSize)), nil
+}
+
+// appendState appends the state of c to dst.
+func (c *RC4) appendState(dst []byte) []byte {
+	dst = append(dst, c.s[:]...)
+	return append(dst, c.i, c.j)
+}
+
+// UnmarshalBinary sets the state of c to data from MarshalBinary. If data is not a valid state,
+// UnmarshalBinary returns ErrInvalidRC4State and c is unchanged.
+func (c *RC4) UnmarshalBinary(data []byte) error {
+	if len(data) != rc4StateSize {
+		return ErrInvalidRC4State
+	}
+
+	// The state must be a permutation of 0-255
+	var seen [256]bool
+	for _, b := range data[:256] {
+		if seen[b] {
+			return ErrInvalidRC4State
+		}
+		seen[b] = true
+	}
+
+	copy(c.s[:], data[:256])
+	c.i, c.j = data[256], data[257]
+	return nil
+}
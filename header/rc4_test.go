@@ -0,0 +1,126 @@
+package header
+
+import (
+	"crypto/rc4"
+	"testing"
+
+	"github.com/kangaroux/go-wow-srp6/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRC4(t *testing.T) {
+	tests := []struct {
+		key      string
+		data     string
+		expected string
+	}{
+		{"Key", "Plaintext", "BBF316E8D940AF0AD3"},
+		{"Wiki", "pedia", "1021BF0420"},
+		{"Secret", "Attack at dawn", "45A01F645FC35B383552544B9BF5"},
+	}
+
+	for _, tt := range tests {
+		c, err := NewRC4([]byte(tt.key))
+		assert.NoError(t, err)
+
+		result := []byte(tt.data)
+		c.XORKeyStream(result, result)
+		assert.Equal(t, internal.MustDecodeHex(tt.expected), result, tt.key)
+	}
+
+	_, err := NewRC4(nil)
+	assert.ErrorIs(t, err, ErrInvalidRC4Key)
+	_, err = NewRC4(make([]byte, 257))
+	assert.ErrorIs(t, err, ErrInvalidRC4Key)
+}
+
+func TestRC4MatchesStdlib(t *testing.T) {
+	key := generateKey(testSessionKey, wrathEncryptKey)
+	c, _ := NewRC4(key)
+	expected, _ := rc4.NewCipher(key)
+
+	// Encrypt in chunks of different sizes, so the state is carried between calls
+	for size := 0; size < 300; size += 7 {
+		data := make([]byte, size)
+		for i := range data {
+			data[i] = byte(i * size)
+		}
+
+		result := make([]byte, size)
+		c.XORKeyStream(result, data)
+		expected.XORKeyStream(data, data)
+		assert.Equal(t, data, result, size)
+	}
+}
+
+func TestRC4Discard(t *testing.T) {
+	key := []byte("Key")
+	c, _ := NewRC4(key)
+	expected, _ := NewRC4(key)
+
+	c.Discard(1000)
+	expected.XORKeyStream(make([]byte, 1000), make([]byte, 1000))
+	assert.Equal(t, expected, c)
+}
+
+func TestRC4Clone(t *testing.T) {
+	c, _ := NewRC4([]byte("Key"))
+	c.Discard(100)
+
+	clone := c.Clone()
+	a := []byte("hello world")
+	b := []byte("hello world")
+	c.XORKeyStream(a, a)
+	clone.XORKeyStream(b, b)
+	assert.Equal(t, a, b)
+
+	// The clone is independent
+	c.Discard(1)
+	c.XORKeyStream(a, a)
+	clone.XORKeyStream(b, b)
+	assert.NotEqual(t, a, b)
+}
+
+func TestRC4MarshalBinary(t *testing.T) {
+	c, _ := NewRC4([]byte("Key"))
+	c.Discard(100)
+
+	state, err := c.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Len(t, state, rc4StateSize)
+
+	restored := &RC4{}
+	assert.NoError(t, restored.UnmarshalBinary(state))
+	assert.Equal(t, c, restored)
+
+	a := []byte("hello world")
+	b := []byte("hello world")
+	c.XORKeyStream(a, a)
+	restored.XORKeyStream(b, b)
+	assert.Equal(t, a, b)
+}
+
+func TestRC4UnmarshalBinaryInvalid(t *testing.T) {
+	c, _ := NewRC4([]byte("Key"))
+	expected := c.Clone()
+
+	state, _ := c.MarshalBinary()
+	assert.ErrorIs(t, c.UnmarshalBinary(state[:rc4StateSize-1]), ErrInvalidRC4State)
+
+	// The permutation has a duplicate
+	state[0] = state[1]
+	assert.ErrorIs(t, c.UnmarshalBinary(state), ErrInvalidRC4State)
+
+	assert.Equal(t, expected, c, "unchanged")
+}
+
+func TestRC4Allocs(t *testing.T) {
+	c, _ := NewRC4([]byte("Key"))
+	data := make([]byte, 64)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		c.XORKeyStream(data, data)
+		c.Discard(1024)
+	})
+	assert.Zero(t, allocs)
+}
@@ -0,0 +1,166 @@
+package header
+
+import "sync"
+
+// rollingHeader holds the ciphers and the methods shared by [VanillaHeader] and [TBCHeader], which
+// only differ in how the key is derived from the session key.
+type rollingHeader struct {
+	decryptCipher *rollingCipher
+	encryptCipher *rollingCipher
+
+	decryptMutex sync.Mutex
+	encryptMutex sync.Mutex
+}
+
+// Encode returns a header with opcode and size. Encode expects size to not include the
+// 2 bytes for the opcode, and will add +2 to size. Headers will automatically be encrypted
+// if Init was called.
+func (h *rollingHeader) Encode(opcode uint16, size uint32) ([]byte, error) {
+	return h.AppendEncode(nil, opcode, size)
+}
+
+// AppendEncode is like Encode, but appends the header to dst and returns the extended slice, so a
+// buffer can be reused between packets.
+func (h *rollingHeader) AppendEncode(dst []byte, opcode uint16, size uint32) ([]byte, error) {
+	dst, err := appendSmallHeader(dst, opcode, size)
+	if err != nil {
+		return nil, err
+	}
+
+	h.encryptIfInit(dst[len(dst)-smallServerHeaderSize:])
+
+	return dst, nil
+}
+
+// Decrypt decrypts a client header in-place. If the decrypt cipher is not initialized, Decrypt returns
+// ErrCryptoNotInitialized. Decrypt is safe to use concurrently. Client packet headers must be decrypted
+// once the client has authenticated with the world/realm server.
+func (h *rollingHeader) Decrypt(data []byte) error {
+	h.decryptMutex.Lock()
+	defer h.decryptMutex.Unlock()
+
+	if h.decryptCipher == nil {
+		return ErrCryptoNotInitialized
+	}
+
+	h.decryptCipher.decrypt(data)
+	return nil
+}
+
+// Encrypt encrypts a server header in-place. If the encrypt cipher is not initialized, Encrypt returns
+// ErrCryptoNotInitialized. Encrypt is safe to use concurrently. Server packet headers must be encrypted
+// once the client has authenticated with the world/realm server. Encode will call Encrypt once
+// Init has been called.
+func (h *rollingHeader) Encrypt(data []byte) error {
+	h.encryptMutex.Lock()
+	defer h.encryptMutex.Unlock()
+
+	if h.encryptCipher == nil {
+		return ErrCryptoNotInitialized
+	}
+
+	h.encryptCipher.encrypt(data)
+	return nil
+}
+
+// EncryptServer encrypts a server header in-place. It is the same as Encrypt.
+func (h *rollingHeader) EncryptServer(data []byte) error {
+	return h.Encrypt(data)
+}
+
+// DecryptClient decrypts a client header in-place. It is the same as Decrypt.
+func (h *rollingHeader) DecryptClient(data []byte) error {
+	return h.Decrypt(data)
+}
+
+// ClientHeaderSize returns the size of a client header, which is always 6 bytes.
+func (h *rollingHeader) ClientHeaderSize() int {
+	return ClientHeaderSize
+}
+
+// ServerHeaderSize returns the size of a server header, which is always 4 bytes.
+func (h *rollingHeader) ServerHeaderSize(size uint32) int {
+	return smallServerHeaderSize
+}
+
+// initKey sets up both ciphers with key.
+func (h *rollingHeader) initKey(key []byte) {
+	// Take the locks so Init can be called while another goroutine is using the header
+	h.decryptMutex.Lock()
+	h.decryptCipher = newRollingCipher(key)
+	h.decryptMutex.Unlock()
+
+	h.encryptMutex.Lock()
+	h.encryptCipher = newRollingCipher(key)
+	h.encryptMutex.Unlock()
+}
+
+// encryptIfInit encrypts data in-place if Init has been called.
+func (h *rollingHeader) encryptIfInit(data []byte) {
+	h.encryptMutex.Lock()
+	if h.encryptCipher != nil {
+		h.encryptCipher.encrypt(data)
+	}
+	h.encryptMutex.Unlock()
+}
+
+// decryptIfInit decrypts data in-place if Init has been called.
+func (h *rollingHeader) decryptIfInit(data []byte) {
+	h.decryptMutex.Lock()
+	if h.decryptCipher != nil {
+		h.decryptCipher.decrypt(data)
+	}
+	h.decryptMutex.Unlock()
+}
+
+// appendSmallHeader appends an unencrypted 4 byte server header to dst, as used by Vanilla and TBC.
+//
+// The header format is: <size><opcode>
+// <size> is 2 bytes big endian
+// <opcode> is 2 bytes little endian
+func appendSmallHeader(dst []byte, opcode uint16, size uint32) ([]byte, error) {
+	// Include the opcode in the size
+	size += 2
+
+	if size > smallSizeFieldMaxValue {
+		return nil, ErrHeaderSizeTooLarge
+	}
+
+	return append(dst,
+		byte(size>>8),
+		byte(size),
+		byte(opcode),
+		byte(opcode>>8),
+	), nil
+}
+
+// rollingCipher is the header cipher used by Vanilla and TBC. Each byte is XORed with the next
+// byte of the key, then the previous encrypted byte is added to it. Each direction has its own
+// cipher, since the index and previous byte must stay in sync with the other side.
+type rollingCipher struct {
+	key   []byte
+	index int
+	last  byte
+}
+
+func newRollingCipher(key []byte) *rollingCipher {
+	return &rollingCipher{key: append([]byte(nil), key...)}
+}
+
+// encrypt encrypts data in-place.
+func (c *rollingCipher) encrypt(data []byte) {
+	for i, b := range data {
+		c.last = (b ^ c.key[c.index]) + c.last
+		c.index = (c.index + 1) % len(c.key)
+		data[i] = c.last
+	}
+}
+
+// decrypt decrypts data in-place.
+func (c *rollingCipher) decrypt(data []byte) {
+	for i, b := range data {
+		data[i] = (b - c.last) ^ c.key[c.index]
+		c.index = (c.index + 1) % len(c.key)
+		c.last = b
+	}
+}
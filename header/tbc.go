@@ -0,0 +1,32 @@
+package header
+
+// tbcSeed is the HMAC key used to derive the TBC header key from the session key.
+var tbcSeed = []byte{
+	0x38, 0xA7, 0x83, 0x15, 0xF8, 0x92, 0x25, 0x30,
+	0x71, 0x98, 0x67, 0xB1, 0x8C, 0x04, 0xE2, 0xAA,
+}
+
+// TBCHeader is used for encrypting/decrypting world packet headers in TBC (2.4.3).
+// Once the client has authenticated, all incoming/outgoing headers must be encrypted.
+//
+// TBC uses the same rolling cipher as [VanillaHeader], but the key is a 20 byte HMAC-SHA1 of the
+// session key instead of the session key itself. Server headers are always 4 bytes, and client
+// headers are 6 bytes.
+type TBCHeader struct {
+	// MaxClientSize is the largest client packet body accepted by DecodeClientHeader. Defaults to
+	// DefaultMaxClientSize.
+	MaxClientSize uint32
+
+	rollingHeader
+}
+
+// Init sets up the ciphers using sessionKey. Init must be called before trying to
+// use [Encrypt] or [Decrypt]. If sessionKey is empty, Init returns ErrInvalidKeySize.
+func (h *TBCHeader) Init(sessionKey []byte) error {
+	if len(sessionKey) == 0 {
+		return ErrInvalidKeySize
+	}
+
+	h.initKey(generateKey(sessionKey, tbcSeed))
+	return nil
+}
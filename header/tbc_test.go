@@ -0,0 +1,73 @@
+package header
+
+import (
+	"testing"
+
+	"github.com/kangaroux/go-wow-srp6/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTBCEncrypt(t *testing.T) {
+	rows := internal.MustLoadTestData("../testdata/header/tbc_header.csv")
+
+	for _, row := range rows {
+		sessionKey := internal.MustDecodeHex(row[0])
+		data := internal.MustDecodeHex(row[1])
+		expected := internal.MustDecodeHex(row[2])
+
+		h := &TBCHeader{}
+		assert.NoError(t, h.Init(sessionKey))
+
+		// The cipher state carries over between headers
+		for i := 0; i < len(data); i += smallServerHeaderSize {
+			assert.NoError(t, h.Encrypt(data[i:i+smallServerHeaderSize]))
+		}
+		assert.Equal(t, expected, data)
+	}
+}
+
+func TestTBCDecrypt(t *testing.T) {
+	rows := internal.MustLoadTestData("../testdata/header/tbc_header.csv")
+
+	for _, row := range rows {
+		sessionKey := internal.MustDecodeHex(row[0])
+		data := internal.MustDecodeHex(row[1])
+		expected := internal.MustDecodeHex(row[3])
+
+		h := &TBCHeader{}
+		assert.NoError(t, h.Init(sessionKey))
+
+		for i := 0; i < len(data); i += smallClientHeaderSize {
+			assert.NoError(t, h.Decrypt(data[i:i+smallClientHeaderSize]))
+		}
+		assert.Equal(t, expected, data)
+	}
+}
+
+func TestTBCEncode(t *testing.T) {
+	h := &TBCHeader{}
+
+	header, err := h.Encode(0x1EE, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x00, 0x0C, 0xEE, 0x01}, header)
+
+	_, err = h.Encode(0x1EE, 0xFFFE)
+	assert.ErrorIs(t, err, ErrHeaderSizeTooLarge)
+
+	// Encode encrypts once initialized
+	sessionKey := internal.MustDecodeHex("403FCE7B2B1FCDAE43F118B6C7E517D5A1498088180936A3E45B9888978B7675ECBAA7DB4CA4E8DE")
+	assert.NoError(t, h.Init(sessionKey))
+	header, err = h.Encode(0x1EE, 10)
+	assert.NoError(t, err)
+
+	expected := []byte{0x00, 0x0C, 0xEE, 0x01}
+	newRollingCipher(generateKey(sessionKey, tbcSeed)).encrypt(expected)
+	assert.Equal(t, expected, header)
+}
+
+func TestTBCNotInitialized(t *testing.T) {
+	h := &TBCHeader{}
+	assert.ErrorIs(t, h.Encrypt([]byte{0}), ErrCryptoNotInitialized)
+	assert.ErrorIs(t, h.Decrypt([]byte{0}), ErrCryptoNotInitialized)
+	assert.ErrorIs(t, h.Init(nil), ErrInvalidKeySize)
+}
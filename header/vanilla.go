@@ -0,0 +1,40 @@
+package header
+
+import "errors"
+
+const (
+	// Server headers are <size><opcode>, with a 2 byte size and a 2 byte opcode.
+	smallServerHeaderSize = 4
+
+	// Client headers are <size><opcode>, with a 2 byte size and a 4 byte opcode.
+	smallClientHeaderSize = 6
+
+	// The size field is 2 bytes and includes the opcode
+	smallSizeFieldMaxValue = 0xFFFF
+)
+
+var ErrInvalidKeySize = errors.New("srp/header: key must not be empty")
+
+// VanillaHeader is used for encrypting/decrypting world packet headers in Vanilla (1.12).
+// Once the client has authenticated, all incoming/outgoing headers must be encrypted.
+//
+// Vanilla uses a rolling XOR/add cipher keyed with the 40 byte session key. Server headers are
+// always 4 bytes, and client headers are 6 bytes.
+type VanillaHeader struct {
+	// MaxClientSize is the largest client packet body accepted by DecodeClientHeader. Defaults to
+	// DefaultMaxClientSize.
+	MaxClientSize uint32
+
+	rollingHeader
+}
+
+// Init sets up the ciphers using sessionKey. Init must be called before trying to
+// use [Encrypt] or [Decrypt]. If sessionKey is empty, Init returns ErrInvalidKeySize.
+func (h *VanillaHeader) Init(sessionKey []byte) error {
+	if len(sessionKey) == 0 {
+		return ErrInvalidKeySize
+	}
+
+	h.initKey(sessionKey)
+	return nil
+}
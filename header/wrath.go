@@ -3,7 +3,6 @@ package header
 import (
 	"crypto"
 	"crypto/hmac"
-	"crypto/rc4"
 	"errors"
 	"sync"
 )
@@ -17,6 +16,9 @@ const (
 
 	// Set on MSB of size field (first header byte)
 	largeHeaderFlag = 0x80
+
+	// A marshaled header is the state of the decrypt cipher, then the encrypt cipher
+	wrathStateSize = 2 * rc4StateSize
 )
 
 var (
@@ -36,8 +38,12 @@ var (
 // WrathHeader is used for encrypting/decrypting world packet headers in WotLK.
 // Once the client has authenticated, all incoming/outgoing headers must be encrypted.
 type WrathHeader struct {
-	decryptCipher *rc4.Cipher
-	encryptCipher *rc4.Cipher
+	// MaxClientSize is the largest client packet body accepted by DecodeClientHeader. Defaults to
+	// DefaultMaxClientSize.
+	MaxClientSize uint32
+
+	decryptCipher *RC4
+	encryptCipher *RC4
 
 	decryptMutex sync.Mutex
 	encryptMutex sync.Mutex
@@ -47,6 +53,12 @@ type WrathHeader struct {
 // 2 bytes for the opcode, and will add +2 to size. In WotLK, server headers can be either
 // 4 or 5 bytes. Headers will automatically be encrypted if [Init] was called.
 func (h *WrathHeader) Encode(opcode uint16, size uint32) ([]byte, error) {
+	return h.AppendEncode(nil, opcode, size)
+}
+
+// AppendEncode is like [WrathHeader.Encode], but appends the header to dst and returns the
+// extended slice, so a buffer can be reused between packets.
+func (h *WrathHeader) AppendEncode(dst []byte, opcode uint16, size uint32) ([]byte, error) {
 	// Include the opcode in the size
 	size += 2
 
@@ -54,7 +66,7 @@ func (h *WrathHeader) Encode(opcode uint16, size uint32) ([]byte, error) {
 		return nil, ErrHeaderSizeTooLarge
 	}
 
-	var header []byte
+	start := len(dst)
 
 	// The size field in the header can be 2 or 3 bytes. If the size field is 3 bytes, the MSB of the
 	// size will be set.
@@ -63,29 +75,18 @@ func (h *WrathHeader) Encode(opcode uint16, size uint32) ([]byte, error) {
 	// <size> is 2-3 bytes big endian
 	// <opcode> is 2 bytes little endian
 	if size > largeHeaderThreshold {
-		header = []byte{
-			byte(size>>16) | largeHeaderFlag,
-			byte(size >> 8),
-			byte(size),
-			byte(opcode),
-			byte(opcode >> 8),
-		}
-	} else {
-		header = []byte{
-			byte(size >> 8),
-			byte(size),
-			byte(opcode),
-			byte(opcode >> 8),
-		}
+		dst = append(dst, byte(size>>16)|largeHeaderFlag)
 	}
+	dst = append(dst,
+		byte(size>>8),
+		byte(size),
+		byte(opcode),
+		byte(opcode>>8),
+	)
 
-	if h.encryptCipher != nil {
-		if err := h.Encrypt(header); err != nil {
-			return nil, err
-		}
-	}
+	h.encryptIfInit(dst[start:])
 
-	return header, nil
+	return dst, nil
 }
 
 // Init sets up the ciphers using sessionKey. Init must be called before trying to
@@ -97,21 +98,27 @@ func (h *WrathHeader) Init(sessionKey []byte) error {
 // InitKeys initializes the ciphers. [Init] should be used instead, unless for some reason
 // different keys are needed.
 func (h *WrathHeader) InitKeys(sessionKey, decryptKey, encryptKey []byte) error {
-	decryptCipher, err := rc4.NewCipher(h.generateKey(sessionKey, decryptKey))
+	decryptCipher, err := NewRC4(generateKey(sessionKey, decryptKey))
 	if err != nil {
 		return err
 	}
 
-	encryptCipher, err := rc4.NewCipher(h.generateKey(sessionKey, encryptKey))
+	encryptCipher, err := NewRC4(generateKey(sessionKey, encryptKey))
 	if err != nil {
 		return err
 	}
 
+	drop1024(decryptCipher)
+	drop1024(encryptCipher)
+
+	// Take the locks so Init can be called while another goroutine is using the header
+	h.decryptMutex.Lock()
 	h.decryptCipher = decryptCipher
-	drop1024(h.decryptCipher)
+	h.decryptMutex.Unlock()
 
+	h.encryptMutex.Lock()
 	h.encryptCipher = encryptCipher
-	drop1024(h.encryptCipher)
+	h.encryptMutex.Unlock()
 
 	return nil
 }
@@ -120,14 +127,14 @@ func (h *WrathHeader) InitKeys(sessionKey, decryptKey, encryptKey []byte) error
 // ErrCryptoNotInitialized. Decrypt is safe to use concurrently. Client packet headers must be decrypted
 // once the client has authenticated with the world/realm server.
 func (h *WrathHeader) Decrypt(data []byte) error {
+	h.decryptMutex.Lock()
+	defer h.decryptMutex.Unlock()
+
 	if h.decryptCipher == nil {
 		return ErrCryptoNotInitialized
 	}
 
-	h.decryptMutex.Lock()
 	h.decryptCipher.XORKeyStream(data, data)
-	h.decryptMutex.Unlock()
-
 	return nil
 }
 
@@ -136,19 +143,89 @@ func (h *WrathHeader) Decrypt(data []byte) error {
 // once the client has authenticated with the world/realm server. [Encode] will call Encrypt once
 // [Init] has been called.
 func (h *WrathHeader) Encrypt(data []byte) error {
+	h.encryptMutex.Lock()
+	defer h.encryptMutex.Unlock()
+
 	if h.encryptCipher == nil {
 		return ErrCryptoNotInitialized
 	}
 
-	h.encryptMutex.Lock()
 	h.encryptCipher.XORKeyStream(data, data)
+	return nil
+}
+
+// MarshalBinary returns the state of the ciphers, so the header can be checkpointed, or moved to
+// another process, and resumed with [WrathHeader.UnmarshalBinary]. The state includes the keys,
+// so it must be kept as secret as the session key. If Init hasn't been called, MarshalBinary
+// returns ErrCryptoNotInitialized.
+func (h *WrathHeader) MarshalBinary() ([]byte, error) {
+	return h.appendState(make([]byte, 0, wrathStateSize))
+}
+
+// UnmarshalBinary sets the ciphers to the state from [WrathHeader.MarshalBinary]. It can be used
+// instead of Init. If data is not a valid state, UnmarshalBinary returns ErrInvalidRC4State and
+// the header is unchanged.
+func (h *WrathHeader) UnmarshalBinary(data []byte) error {
+	if len(data) != wrathStateSize {
+		return ErrInvalidRC4State
+	}
+
+	decryptCipher, encryptCipher := &RC4{}, &RC4{}
+	if err := decryptCipher.UnmarshalBinary(data[:rc4StateSize]); err != nil {
+		return err
+	}
+	if err := encryptCipher.UnmarshalBinary(data[rc4StateSize:]); err != nil {
+		return err
+	}
+
+	h.decryptMutex.Lock()
+	h.decryptCipher = decryptCipher
+	h.decryptMutex.Unlock()
+
+	h.encryptMutex.Lock()
+	h.encryptCipher = encryptCipher
 	h.encryptMutex.Unlock()
 
 	return nil
 }
 
-// generateKey returns a cipher key based on key.
-func (h *WrathHeader) generateKey(sessionKey, key []byte) []byte {
+// appendState appends the state of both ciphers to dst. Both locks are held, so the state is
+// consistent even if the header is in use.
+func (h *WrathHeader) appendState(dst []byte) ([]byte, error) {
+	h.decryptMutex.Lock()
+	defer h.decryptMutex.Unlock()
+	h.encryptMutex.Lock()
+	defer h.encryptMutex.Unlock()
+
+	if h.decryptCipher == nil || h.encryptCipher == nil {
+		return nil, ErrCryptoNotInitialized
+	}
+
+	dst = h.decryptCipher.appendState(dst)
+	return h.encryptCipher.appendState(dst), nil
+}
+
+// encryptIfInit encrypts data in-place if [WrathHeader.Init] has been called.
+func (h *WrathHeader) encryptIfInit(data []byte) {
+	h.encryptMutex.Lock()
+	if h.encryptCipher != nil {
+		h.encryptCipher.XORKeyStream(data, data)
+	}
+	h.encryptMutex.Unlock()
+}
+
+// decryptIfInit decrypts data in-place if [WrathHeader.Init] has been called.
+func (h *WrathHeader) decryptIfInit(data []byte) {
+	h.decryptMutex.Lock()
+	if h.decryptCipher != nil {
+		h.decryptCipher.XORKeyStream(data, data)
+	}
+	h.decryptMutex.Unlock()
+}
+
+// generateKey returns a cipher key based on key. The key is HMAC-SHA1(key, sessionKey), which is
+// used by both TBC and WotLK.
+func generateKey(sessionKey, key []byte) []byte {
 	hash := hmac.New(crypto.SHA1.New, key)
 	hash.Write(sessionKey)
 	return hash.Sum(nil)
@@ -157,7 +234,6 @@ func (h *WrathHeader) generateKey(sessionKey, key []byte) []byte {
 // drop1024 discards the first 1024 bytes of the keystream. This is a protection against a
 // keystream attack. The client and server ciphers must stay in sync, since the client drops
 // 1024, so does the server.
-func drop1024(cipher *rc4.Cipher) {
-	var drop1024 [1024]byte
-	cipher.XORKeyStream(drop1024[:], drop1024[:])
+func drop1024(cipher *RC4) {
+	cipher.Discard(1024)
 }
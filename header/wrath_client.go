@@ -0,0 +1,177 @@
+package header
+
+import (
+	"errors"
+	"io"
+)
+
+const (
+	// The client size field is 2 bytes and includes the 4 byte opcode
+	clientSizeFieldMaxValue = 0xFFFF
+
+	// The server size field includes the 2 byte opcode
+	serverOpcodeSize = 2
+)
+
+var ErrInvalidServerHeader = errors.New("srp/header: server header must be 4 or 5 bytes")
+
+// WrathClientHeader is the client side of [WrathHeader], for bots, proxies and test clients. It
+// encrypts client headers and decrypts server headers, using the same keys as the server but
+// swapped.
+type WrathClientHeader struct {
+	h WrathHeader
+
+	// The header read so far by ReadServerHeader, already decrypted
+	partial    [5]byte
+	partialLen int
+}
+
+// Init sets up the ciphers using sessionKey. Init must be called before trying to
+// use [Encrypt] or [Decrypt].
+func (c *WrathClientHeader) Init(sessionKey []byte) error {
+	// The client encrypts with the key the server decrypts with, and vice versa
+	return c.h.InitKeys(sessionKey, wrathEncryptKey, wrathDecryptKey)
+}
+
+// Encode returns a client header with opcode and size. Encode expects size to not include the
+// 4 bytes for the opcode, and will add +4 to size. Client headers are always 6 bytes. Headers will
+// automatically be encrypted if [Init] was called.
+func (c *WrathClientHeader) Encode(opcode uint32, size uint32) ([]byte, error) {
+	return c.AppendEncode(nil, opcode, size)
+}
+
+// AppendEncode is like [WrathClientHeader.Encode], but appends the header to dst and returns the
+// extended slice, so a buffer can be reused between packets.
+func (c *WrathClientHeader) AppendEncode(dst []byte, opcode uint32, size uint32) ([]byte, error) {
+	// Include the opcode in the size
+	size += clientOpcodeSize
+
+	if size > clientSizeFieldMaxValue {
+		return nil, ErrHeaderSizeTooLarge
+	}
+
+	// The header format is: <size><opcode>
+	// <size> is 2 bytes big endian
+	// <opcode> is 4 bytes little endian
+	dst = append(dst,
+		byte(size>>8),
+		byte(size),
+		byte(opcode),
+		byte(opcode>>8),
+		byte(opcode>>16),
+		byte(opcode>>24),
+	)
+
+	c.h.encryptIfInit(dst[len(dst)-ClientHeaderSize:])
+
+	return dst, nil
+}
+
+// DecodeServerHeader decrypts a server header in-place if [WrathClientHeader.Init] was called, and
+// returns the opcode and the size of the body, not including the opcode. The header must be 5
+// bytes if the large header flag is set, and 4 bytes otherwise.
+func (c *WrathClientHeader) DecodeServerHeader(data []byte) (opcode uint16, size uint32, err error) {
+	if len(data) != 4 && len(data) != 5 {
+		return 0, 0, ErrInvalidServerHeader
+	}
+	c.h.decryptIfInit(data)
+	if (data[0]&largeHeaderFlag != 0) != (len(data) == 5) {
+		return 0, 0, ErrInvalidServerHeader
+	}
+	return decodeServerHeader(data)
+}
+
+// ReadServerHeader reads a server header from r one byte at a time, decrypting each byte if
+// [WrathClientHeader.Init] was called, and returns the opcode and the size of the body. The first
+// byte tells whether the header is 4 or 5 bytes, so exactly one header is read from r and the
+// cipher stays in sync.
+//
+// If r returns an error part way through a header, the bytes read so far are kept and the next
+// call continues the same header. ReadServerHeader must not be called concurrently.
+func (c *WrathClientHeader) ReadServerHeader(r io.ByteReader) (opcode uint16, size uint32, err error) {
+	for {
+		need := 4
+		if c.partialLen > 0 && c.partial[0]&largeHeaderFlag != 0 {
+			need = 5
+		}
+		if c.partialLen == need {
+			break
+		}
+
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, 0, err
+		}
+
+		c.partial[c.partialLen] = b
+		c.h.decryptIfInit(c.partial[c.partialLen : c.partialLen+1])
+		c.partialLen++
+	}
+
+	header := c.partial[:c.partialLen]
+	c.partialLen = 0
+	return decodeServerHeader(header)
+}
+
+// MarshalBinary returns the state of the ciphers, including any part of a server header read by
+// ReadServerHeader, so the header can be resumed with [WrathClientHeader.UnmarshalBinary]. The
+// state includes the keys, so it must be kept as secret as the session key. MarshalBinary must
+// not be called at the same time as ReadServerHeader. If Init hasn't been called, MarshalBinary
+// returns ErrCryptoNotInitialized.
+func (c *WrathClientHeader) MarshalBinary() ([]byte, error) {
+	data, err := c.h.appendState(make([]byte, 0, wrathStateSize+1+len(c.partial)))
+	if err != nil {
+		return nil, err
+	}
+	data = append(data, byte(c.partialLen))
+	return append(data, c.partial[:c.partialLen]...), nil
+}
+
+// UnmarshalBinary sets the ciphers to the state from [WrathClientHeader.MarshalBinary]. It can be
+// used instead of Init. If data is not a valid state, UnmarshalBinary returns ErrInvalidRC4State
+// and the header is unchanged.
+func (c *WrathClientHeader) UnmarshalBinary(data []byte) error {
+	if len(data) < wrathStateSize+1 {
+		return ErrInvalidRC4State
+	}
+
+	// A complete header is never kept, so at most 4 bytes are left over
+	partial := data[wrathStateSize+1:]
+	if int(data[wrathStateSize]) != len(partial) || len(partial) >= len(c.partial) {
+		return ErrInvalidRC4State
+	}
+
+	if err := c.h.UnmarshalBinary(data[:wrathStateSize]); err != nil {
+		return err
+	}
+	c.partialLen = copy(c.partial[:], partial)
+	return nil
+}
+
+// Decrypt decrypts a server header in-place. If the decrypt cipher is not initialized, Decrypt
+// returns ErrCryptoNotInitialized. Decrypt is safe to use concurrently.
+func (c *WrathClientHeader) Decrypt(data []byte) error {
+	return c.h.Decrypt(data)
+}
+
+// Encrypt encrypts a client header in-place. If the encrypt cipher is not initialized, Encrypt
+// returns ErrCryptoNotInitialized. Encrypt is safe to use concurrently. [Encode] will call Encrypt
+// once [Init] has been called.
+func (c *WrathClientHeader) Encrypt(data []byte) error {
+	return c.h.Encrypt(data)
+}
+
+// decodeServerHeader returns the opcode and body size of a decrypted 4 or 5 byte server header.
+func decodeServerHeader(data []byte) (opcode uint16, size uint32, err error) {
+	if len(data) == 5 {
+		size = uint32(data[0]&^largeHeaderFlag)<<16 | uint32(data[1])<<8 | uint32(data[2])
+	} else {
+		size = uint32(data[0])<<8 | uint32(data[1])
+	}
+	opcode = uint16(data[len(data)-2]) | uint16(data[len(data)-1])<<8
+
+	if size < serverOpcodeSize {
+		return 0, 0, ErrHeaderSizeTooSmall
+	}
+	return opcode, size - serverOpcodeSize, nil
+}
@@ -0,0 +1,192 @@
+package header
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/kangaroux/go-wow-srp6/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+var testSessionKey = internal.MustDecodeHex("403FCE7B2B1FCDAE43F118B6C7E517D5A1498088180936A3E45B9888978B7675ECBAA7DB4CA4E8DE")
+
+func TestWrathClientEncode(t *testing.T) {
+	c := &WrathClientHeader{}
+
+	header, err := c.Encode(0x12345678, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x00, 0x0E, 0x78, 0x56, 0x34, 0x12}, header)
+
+	_, err = c.Encode(0x1ED, 0xFFFC)
+	assert.ErrorIs(t, err, ErrHeaderSizeTooLarge)
+
+	// The server decodes what the client encodes
+	server := &WrathHeader{}
+	assert.NoError(t, server.Init(testSessionKey))
+	assert.NoError(t, c.Init(testSessionKey))
+
+	for i := uint32(0); i < 3; i++ {
+		header, err = c.Encode(0x1ED+i, 100*i)
+		assert.NoError(t, err)
+
+		opcode, size, err := server.DecodeClientHeader(header)
+		assert.NoError(t, err)
+		assert.Equal(t, 0x1ED+i, opcode)
+		assert.Equal(t, 100*i, size)
+	}
+}
+
+func TestWrathClientDecodeServerHeader(t *testing.T) {
+	server := &WrathHeader{}
+	c := &WrathClientHeader{}
+
+	// Unencrypted
+	opcode, size, err := c.DecodeServerHeader([]byte{0x00, 0x0C, 0xEE, 0x01})
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(0x1EE), opcode)
+	assert.Equal(t, uint32(10), size)
+
+	opcode, size, err = c.DecodeServerHeader([]byte{0x81, 0x00, 0x02, 0xEE, 0x01})
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(0x1EE), opcode)
+	assert.Equal(t, uint32(0x10000), size)
+
+	// The client decodes what the server encodes, with small and large headers
+	assert.NoError(t, server.Init(testSessionKey))
+	assert.NoError(t, c.Init(testSessionKey))
+
+	for _, expectedSize := range []uint32{0, 10, 0x7FFD, 0x7FFE, 0x10000} {
+		header, err := server.Encode(0x1EE, expectedSize)
+		assert.NoError(t, err)
+
+		opcode, size, err := c.DecodeServerHeader(header)
+		assert.NoError(t, err)
+		assert.Equal(t, uint16(0x1EE), opcode)
+		assert.Equal(t, expectedSize, size)
+	}
+}
+
+func TestWrathClientDecodeServerHeaderInvalid(t *testing.T) {
+	c := &WrathClientHeader{}
+
+	_, _, err := c.DecodeServerHeader([]byte{0x00, 0x0C, 0xEE})
+	assert.ErrorIs(t, err, ErrInvalidServerHeader)
+
+	// The large header flag doesn't match the length
+	_, _, err = c.DecodeServerHeader([]byte{0x80, 0x0C, 0xEE, 0x01})
+	assert.ErrorIs(t, err, ErrInvalidServerHeader)
+	_, _, err = c.DecodeServerHeader([]byte{0x00, 0x00, 0x0C, 0xEE, 0x01})
+	assert.ErrorIs(t, err, ErrInvalidServerHeader)
+
+	_, _, err = c.DecodeServerHeader([]byte{0x00, 0x01, 0xEE, 0x01})
+	assert.ErrorIs(t, err, ErrHeaderSizeTooSmall)
+}
+
+func TestWrathClientNotInitialized(t *testing.T) {
+	c := &WrathClientHeader{}
+	assert.ErrorIs(t, c.Encrypt([]byte{0}), ErrCryptoNotInitialized)
+	assert.ErrorIs(t, c.Decrypt([]byte{0}), ErrCryptoNotInitialized)
+}
+
+// flakyReader returns errTimeout every other read, to test resuming a partial header.
+type flakyReader struct {
+	r    io.ByteReader
+	fail bool
+}
+
+var errTimeout = errors.New("timeout")
+
+func (r *flakyReader) ReadByte() (byte, error) {
+	r.fail = !r.fail
+	if r.fail {
+		return 0, errTimeout
+	}
+	return r.r.ReadByte()
+}
+
+func TestWrathClientReadServerHeader(t *testing.T) {
+	server := &WrathHeader{}
+	c := &WrathClientHeader{}
+	assert.NoError(t, server.Init(testSessionKey))
+	assert.NoError(t, c.Init(testSessionKey))
+
+	sizes := []uint32{10, 0x10000, 0, 0x7FFE, 0x7FFD}
+
+	var stream bytes.Buffer
+	for i, size := range sizes {
+		header, err := server.Encode(uint16(0x100+i), size)
+		assert.NoError(t, err)
+		stream.Write(header)
+	}
+
+	r := bufio.NewReader(&stream)
+	for i, expectedSize := range sizes {
+		opcode, size, err := c.ReadServerHeader(r)
+		assert.NoError(t, err)
+		assert.Equal(t, uint16(0x100+i), opcode)
+		assert.Equal(t, expectedSize, size)
+	}
+
+	_, _, err := c.ReadServerHeader(r)
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestWrathClientReadServerHeaderPartial(t *testing.T) {
+	server := &WrathHeader{}
+	c := &WrathClientHeader{}
+	assert.NoError(t, server.Init(testSessionKey))
+	assert.NoError(t, c.Init(testSessionKey))
+
+	var stream bytes.Buffer
+	for _, size := range []uint32{0x10000, 10} {
+		header, _ := server.Encode(0x1EE, size)
+		stream.Write(header)
+	}
+
+	r := &flakyReader{r: &stream}
+	for _, expectedSize := range []uint32{0x10000, 10} {
+		var opcode uint16
+		var size uint32
+		var err error
+
+		// Each byte needs two calls, the first one fails
+		for attempts := 0; ; attempts++ {
+			opcode, size, err = c.ReadServerHeader(r)
+			if err != errTimeout {
+				break
+			}
+			assert.Less(t, attempts, 10)
+		}
+
+		assert.NoError(t, err)
+		assert.Equal(t, uint16(0x1EE), opcode)
+		assert.Equal(t, expectedSize, size)
+	}
+}
+
+func TestWrathClientMarshalBinaryPartial(t *testing.T) {
+	server := &WrathHeader{}
+	c := &WrathClientHeader{}
+	assert.NoError(t, server.Init(testSessionKey))
+	assert.NoError(t, c.Init(testSessionKey))
+
+	header, err := server.Encode(0x1EE, 0x10000)
+	assert.NoError(t, err)
+
+	// Read part of the header, then move the client
+	_, _, err = c.ReadServerHeader(bufio.NewReader(bytes.NewReader(header[:3])))
+	assert.ErrorIs(t, err, io.EOF)
+
+	state, err := c.MarshalBinary()
+	assert.NoError(t, err)
+	restored := &WrathClientHeader{}
+	assert.NoError(t, restored.UnmarshalBinary(state))
+
+	opcode, size, err := restored.ReadServerHeader(bufio.NewReader(bytes.NewReader(header[3:])))
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(0x1EE), opcode)
+	assert.Equal(t, uint32(0x10000), size)
+}
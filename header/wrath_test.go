@@ -1,7 +1,6 @@
 package header
 
 import (
-	"crypto/rc4"
 	"testing"
 
 	"github.com/kangaroux/go-wow-srp6/internal"
@@ -15,8 +14,7 @@ func TestGenerateKey(t *testing.T) {
 		expected := internal.MustDecodeHex(row[2])
 		sessionKey := internal.MustDecodeHex(row[0])
 		fixedKey := internal.MustDecodeHex(row[1])
-		wrath := &WrathHeader{}
-		assert.Equal(t, expected, wrath.generateKey(sessionKey, fixedKey))
+		assert.Equal(t, expected, generateKey(sessionKey, fixedKey))
 	}
 }
 func TestDrop1024(t *testing.T) {
@@ -25,13 +23,13 @@ func TestDrop1024(t *testing.T) {
 	result := make([]byte, len(data))
 
 	// Test the output when no bytes are dropped
-	c, _ := rc4.NewCipher(key)
+	c, _ := NewRC4(key)
 	copy(result, data)
 	c.XORKeyStream(result, data)
 	assert.Equal(t, internal.MustDecodeHex("B53577EC1E7FEAE6CDBB5C"), result)
 
 	// Test the output when the first 1024 bytes are dropped (RC4-drop1024)
-	c, _ = rc4.NewCipher(key)
+	c, _ = NewRC4(key)
 	drop1024(c)
 	copy(result, data)
 	c.XORKeyStream(result, data)
@@ -61,3 +59,85 @@ func TestEncrypt(t *testing.T) {
 
 	assert.Equal(t, expected, data)
 }
+
+func TestWrathMarshalBinary(t *testing.T) {
+	server := &WrathHeader{}
+	c := &WrathClientHeader{}
+
+	_, err := server.MarshalBinary()
+	assert.ErrorIs(t, err, ErrCryptoNotInitialized)
+	_, err = c.MarshalBinary()
+	assert.ErrorIs(t, err, ErrCryptoNotInitialized)
+
+	assert.NoError(t, server.Init(testSessionKey))
+	assert.NoError(t, c.Init(testSessionKey))
+
+	// exchange sends a header each way and checks that both sides decode it
+	exchange := func(server *WrathHeader, c *WrathClientHeader, i uint32) {
+		header, err := c.Encode(0x1ED+i, i)
+		assert.NoError(t, err)
+		opcode, size, err := server.DecodeClientHeader(header)
+		assert.NoError(t, err)
+		assert.Equal(t, 0x1ED+i, opcode)
+		assert.Equal(t, i, size)
+
+		header, err = server.Encode(uint16(0x1EE+i), 0x10000+i)
+		assert.NoError(t, err)
+		serverOpcode, size, err := c.DecodeServerHeader(header)
+		assert.NoError(t, err)
+		assert.Equal(t, uint16(0x1EE+i), serverOpcode)
+		assert.Equal(t, 0x10000+i, size)
+	}
+
+	for i := uint32(0); i < 5; i++ {
+		exchange(server, c, i)
+	}
+
+	serverState, err := server.MarshalBinary()
+	assert.NoError(t, err)
+	assert.Len(t, serverState, wrathStateSize)
+	clientState, err := c.MarshalBinary()
+	assert.NoError(t, err)
+
+	// The session resumes from the checkpoint
+	restoredServer := &WrathHeader{}
+	restoredClient := &WrathClientHeader{}
+	assert.NoError(t, restoredServer.UnmarshalBinary(serverState))
+	assert.NoError(t, restoredClient.UnmarshalBinary(clientState))
+
+	for i := uint32(5); i < 10; i++ {
+		exchange(restoredServer, restoredClient, i)
+	}
+
+	// The originals are unaffected and continue from the same point
+	for i := uint32(5); i < 10; i++ {
+		exchange(server, c, i)
+	}
+}
+
+func TestWrathUnmarshalBinaryInvalid(t *testing.T) {
+	server := &WrathHeader{}
+	c := &WrathClientHeader{}
+	assert.NoError(t, server.Init(testSessionKey))
+	assert.NoError(t, c.Init(testSessionKey))
+
+	serverState, _ := server.MarshalBinary()
+	clientState, _ := c.MarshalBinary()
+
+	h := &WrathHeader{}
+	assert.ErrorIs(t, h.UnmarshalBinary(serverState[:wrathStateSize-1]), ErrInvalidRC4State)
+	assert.ErrorIs(t, h.UnmarshalBinary(clientState), ErrInvalidRC4State)
+
+	// The encrypt cipher's permutation has a repeated byte
+	invalid := append([]byte{}, serverState...)
+	invalid[rc4StateSize] = invalid[rc4StateSize+1]
+	assert.ErrorIs(t, h.UnmarshalBinary(invalid), ErrInvalidRC4State)
+	_, err := h.MarshalBinary()
+	assert.ErrorIs(t, err, ErrCryptoNotInitialized, "header is unchanged")
+
+	// The partial header length doesn't match
+	client := &WrathClientHeader{}
+	assert.ErrorIs(t, client.UnmarshalBinary(serverState), ErrInvalidRC4State)
+	assert.ErrorIs(t, client.UnmarshalBinary(append(serverState, 2, 0)), ErrInvalidRC4State)
+	assert.ErrorIs(t, client.UnmarshalBinary(append(serverState, 5, 0, 0, 0, 0, 0)), ErrInvalidRC4State)
+}
@@ -0,0 +1,247 @@
+// Package lockout limits failed login attempts per account and per IP address. [Memory] implements
+// [authserver.Limiter].
+//
+// Each key (an account or an IP) counts its failed proofs. Once the count reaches the policy's
+// threshold, the key is locked for the ban duration. Failures decay over time: every Decay period
+// without a new failure forgives one failure.
+//
+// Locking accounts stops an attacker from guessing one account's password from many IPs, but also
+// lets anyone lock an account they know the name of. Choose a short account ban duration.
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/kangaroux/go-wow-srp6/authproto"
+)
+
+// DefaultMaxEntries is the default for [Memory.MaxEntries].
+const DefaultMaxEntries = 65536
+
+// Policy configures when a key is locked.
+type Policy struct {
+	// Threshold is the number of failures which locks the key. Zero disables the policy.
+	Threshold int
+
+	// BanDuration is how long the key stays locked.
+	BanDuration time.Duration
+
+	// Decay is how long it takes for one failure to be forgiven. Zero means failures are only
+	// forgotten once the account logs in, or the key is locked.
+	Decay time.Duration
+}
+
+// LockedError is returned by [Memory.Allow] when the account or IP is locked.
+type LockedError struct {
+	// IP is true if the IP address is locked, and false if the account is locked.
+	IP bool
+
+	Until time.Time
+}
+
+func (e *LockedError) Error() string {
+	if e.IP {
+		return "srp/lockout: ip address locked until " + e.Until.Format(time.RFC3339)
+	}
+	return "srp/lockout: account locked until " + e.Until.Format(time.RFC3339)
+}
+
+// LoginResult returns the result to send to the client. Locked accounts are suspended, and locked
+// IP addresses are banned.
+func (e *LockedError) LoginResult() authproto.LoginResult {
+	if e.IP {
+		return authproto.LoginFailBanned
+	}
+	return authproto.LoginFailSuspended
+}
+
+// Memory keeps failed attempts in memory. It is safe to use concurrently. The fields must not be
+// changed once the limiter is in use.
+type Memory struct {
+	Account Policy
+	IP      Policy
+
+	// MaxEntries is the maximum number of accounts, and of IP addresses, being tracked. Defaults
+	// to DefaultMaxEntries. When a new key is added and the limit is reached, keys with nothing
+	// left to track are pruned first, then the key with the oldest failure is forgotten. Since
+	// anyone can fail logins with made up usernames, MaxEntries should be well above the number of
+	// keys expected to be failing at once.
+	MaxEntries int
+
+	// Now returns the current time. Defaults to [time.Now].
+	Now func() time.Time
+
+	mu       sync.Mutex
+	accounts map[string]*entry
+	ips      map[string]*entry
+}
+
+// entry is the state of a single key.
+type entry struct {
+	failures    int
+	last        time.Time
+	lockedUntil time.Time
+}
+
+// NewMemory returns an in-memory limiter using the account and ip policies.
+func NewMemory(account, ip Policy) *Memory {
+	return &Memory{Account: account, IP: ip}
+}
+
+// Allow returns a [*LockedError] if the IP address or the account is locked. The IP address is
+// checked first.
+func (m *Memory) Allow(ctx context.Context, username, ip string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	now := m.now()
+	if e := m.ips[ip]; e != nil && now.Before(e.lockedUntil) {
+		return &LockedError{IP: true, Until: e.lockedUntil}
+	}
+	if e := m.accounts[username]; e != nil && now.Before(e.lockedUntil) {
+		return &LockedError{Until: e.lockedUntil}
+	}
+	return nil
+}
+
+// Failed counts a failure for the account and the IP address, and locks them if they reached the
+// threshold.
+func (m *Memory) Failed(ctx context.Context, username, ip string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	now := m.now()
+	fail(m.accounts, username, m.Account, now, m.maxEntries())
+	fail(m.ips, ip, m.IP, now, m.maxEntries())
+	return nil
+}
+
+// Succeeded forgets the account's failures. The IP address's failures are kept, so an attacker
+// can't reset them by logging in to their own account.
+func (m *Memory) Succeeded(ctx context.Context, username, ip string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.init()
+
+	if e := m.accounts[username]; e != nil && !m.now().Before(e.lockedUntil) {
+		delete(m.accounts, username)
+	}
+	return nil
+}
+
+// Prune removes keys which are not locked and have no failures left. The limiter never holds more
+// than MaxEntries keys of each kind, but calling Prune periodically frees memory sooner.
+func (m *Memory) Prune() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	prune(m.accounts, m.Account, now)
+	prune(m.ips, m.IP, now)
+}
+
+// Len returns the number of accounts and IP addresses being tracked.
+func (m *Memory) Len() (accounts, ips int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.accounts), len(m.ips)
+}
+
+// init creates the maps, so a Memory built without [NewMemory] works.
+func (m *Memory) init() {
+	if m.accounts == nil {
+		m.accounts = make(map[string]*entry)
+		m.ips = make(map[string]*entry)
+	}
+}
+
+func (m *Memory) maxEntries() int {
+	if m.MaxEntries > 0 {
+		return m.MaxEntries
+	}
+	return DefaultMaxEntries
+}
+
+func (m *Memory) now() time.Time {
+	if m.Now != nil {
+		return m.Now()
+	}
+	return time.Now()
+}
+
+func fail(entries map[string]*entry, key string, p Policy, now time.Time, maxEntries int) {
+	if p.Threshold <= 0 {
+		return
+	}
+
+	e := entries[key]
+	if e == nil {
+		if len(entries) >= maxEntries {
+			prune(entries, p, now)
+		}
+		if len(entries) >= maxEntries {
+			evictOldest(entries)
+		}
+
+		e = &entry{}
+		entries[key] = e
+	}
+
+	// Failures while locked don't extend the ban
+	if now.Before(e.lockedUntil) {
+		return
+	}
+
+	decay(e, p, now)
+	e.failures++
+	e.last = now
+
+	if e.failures >= p.Threshold {
+		e.failures = 0
+		e.lockedUntil = now.Add(p.BanDuration)
+	}
+}
+
+func prune(entries map[string]*entry, p Policy, now time.Time) {
+	for key, e := range entries {
+		decay(e, p, now)
+		if e.failures == 0 && !now.Before(e.lockedUntil) {
+			delete(entries, key)
+		}
+	}
+}
+
+// evictOldest removes the key with the oldest failure.
+func evictOldest(entries map[string]*entry) {
+	var oldestKey string
+	var oldest *entry
+	for key, e := range entries {
+		if oldest == nil || e.last.Before(oldest.last) {
+			oldestKey, oldest = key, e
+		}
+	}
+	delete(entries, oldestKey)
+}
+
+// decay forgives one failure for every Decay period since the last failure.
+func decay(e *entry, p Policy, now time.Time) {
+	if p.Decay <= 0 || e.failures == 0 {
+		return
+	}
+
+	n := now.Sub(e.last) / p.Decay
+	if n <= 0 {
+		return
+	}
+
+	if int64(n) >= int64(e.failures) {
+		e.failures = 0
+	} else {
+		e.failures -= int(n)
+	}
+	e.last = e.last.Add(n * p.Decay)
+}
@@ -0,0 +1,194 @@
+package lockout
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kangaroux/go-wow-srp6/authproto"
+	"github.com/kangaroux/go-wow-srp6/authserver"
+	"github.com/stretchr/testify/assert"
+)
+
+var _ authserver.Limiter = (*Memory)(nil)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func (c *testClock) Add(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestMemory(account, ip Policy) (*Memory, *testClock) {
+	clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	m := NewMemory(account, ip)
+	m.Now = clock.Now
+	return m, clock
+}
+
+func TestAccountLockout(t *testing.T) {
+	ctx := context.Background()
+	m, clock := newTestMemory(Policy{Threshold: 3, BanDuration: time.Minute}, Policy{})
+
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, m.Failed(ctx, "ALICE", "10.0.0.1"))
+		assert.NoError(t, m.Allow(ctx, "ALICE", "10.0.0.1"))
+	}
+	assert.NoError(t, m.Failed(ctx, "ALICE", "10.0.0.2"))
+
+	// The account is locked from every IP
+	err := m.Allow(ctx, "ALICE", "10.0.0.3")
+	var locked *LockedError
+	assert.ErrorAs(t, err, &locked)
+	assert.False(t, locked.IP)
+	assert.Equal(t, clock.now.Add(time.Minute), locked.Until)
+	assert.Equal(t, authproto.LoginFailSuspended, authproto.ResultFromError(err))
+
+	// Other accounts are not locked
+	assert.NoError(t, m.Allow(ctx, "BOB", "10.0.0.1"))
+
+	// Failures while locked don't extend the ban
+	clock.Add(30 * time.Second)
+	assert.NoError(t, m.Failed(ctx, "ALICE", "10.0.0.1"))
+	clock.Add(30 * time.Second)
+	assert.NoError(t, m.Allow(ctx, "ALICE", "10.0.0.1"))
+}
+
+func TestIPLockout(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestMemory(Policy{}, Policy{Threshold: 2, BanDuration: time.Hour})
+
+	assert.NoError(t, m.Failed(ctx, "ALICE", "10.0.0.1"))
+	assert.NoError(t, m.Failed(ctx, "BOB", "10.0.0.1"))
+
+	err := m.Allow(ctx, "CAROL", "10.0.0.1")
+	var locked *LockedError
+	assert.ErrorAs(t, err, &locked)
+	assert.True(t, locked.IP)
+	assert.Equal(t, authproto.LoginFailBanned, authproto.ResultFromError(err))
+
+	assert.NoError(t, m.Allow(ctx, "ALICE", "10.0.0.2"))
+
+	// Logging in doesn't reset the IP
+	assert.NoError(t, m.Succeeded(ctx, "ALICE", "10.0.0.1"))
+	assert.Error(t, m.Allow(ctx, "ALICE", "10.0.0.1"))
+}
+
+func TestDecay(t *testing.T) {
+	ctx := context.Background()
+	m, clock := newTestMemory(Policy{Threshold: 3, BanDuration: time.Minute, Decay: 10 * time.Minute}, Policy{})
+
+	m.Failed(ctx, "ALICE", "")
+	m.Failed(ctx, "ALICE", "")
+
+	// One failure is forgiven
+	clock.Add(10 * time.Minute)
+	m.Failed(ctx, "ALICE", "")
+	assert.NoError(t, m.Allow(ctx, "ALICE", ""))
+
+	m.Failed(ctx, "ALICE", "")
+	assert.Error(t, m.Allow(ctx, "ALICE", ""))
+
+	// Both failures are forgiven
+	clock.Add(time.Minute)
+	m.Failed(ctx, "ALICE", "")
+	m.Failed(ctx, "ALICE", "")
+	clock.Add(25 * time.Minute)
+	m.Failed(ctx, "ALICE", "")
+	m.Failed(ctx, "ALICE", "")
+	assert.NoError(t, m.Allow(ctx, "ALICE", ""))
+}
+
+func TestSucceeded(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestMemory(Policy{Threshold: 2, BanDuration: time.Minute}, Policy{})
+
+	m.Failed(ctx, "ALICE", "")
+	assert.NoError(t, m.Succeeded(ctx, "ALICE", ""))
+	m.Failed(ctx, "ALICE", "")
+	assert.NoError(t, m.Allow(ctx, "ALICE", ""))
+
+	// Logging in doesn't unlock a locked account
+	m.Failed(ctx, "ALICE", "")
+	m.Succeeded(ctx, "ALICE", "")
+	assert.Error(t, m.Allow(ctx, "ALICE", ""))
+}
+
+func TestPrune(t *testing.T) {
+	ctx := context.Background()
+	m, clock := newTestMemory(
+		Policy{Threshold: 2, BanDuration: time.Minute, Decay: time.Minute},
+		Policy{Threshold: 5, BanDuration: time.Minute, Decay: time.Hour},
+	)
+
+	m.Failed(ctx, "ALICE", "10.0.0.1")
+	m.Failed(ctx, "ALICE", "10.0.0.1")
+	m.Failed(ctx, "BOB", "10.0.0.2")
+
+	accounts, ips := m.Len()
+	assert.Equal(t, 2, accounts)
+	assert.Equal(t, 2, ips)
+
+	// BOB's failure hasn't decayed yet and ALICE is still locked
+	clock.Add(59 * time.Second)
+	m.Prune()
+	accounts, _ = m.Len()
+	assert.Equal(t, 2, accounts)
+
+	clock.Add(time.Second)
+	m.Prune()
+	accounts, ips = m.Len()
+	assert.Equal(t, 0, accounts)
+	assert.Equal(t, 2, ips)
+
+	clock.Add(2 * time.Hour)
+	m.Prune()
+	_, ips = m.Len()
+	assert.Equal(t, 0, ips)
+}
+
+func TestStructLiteral(t *testing.T) {
+	ctx := context.Background()
+	m := &Memory{Account: Policy{Threshold: 1, BanDuration: time.Minute}}
+
+	assert.NoError(t, m.Allow(ctx, "ALICE", "10.0.0.1"))
+	assert.NoError(t, m.Succeeded(ctx, "ALICE", "10.0.0.1"))
+	assert.NoError(t, m.Failed(ctx, "ALICE", "10.0.0.1"))
+	assert.Error(t, m.Allow(ctx, "ALICE", "10.0.0.1"))
+}
+
+func TestMaxEntries(t *testing.T) {
+	ctx := context.Background()
+	m, clock := newTestMemory(
+		Policy{Threshold: 2, BanDuration: time.Hour, Decay: time.Minute},
+		Policy{},
+	)
+	m.MaxEntries = 3
+
+	// ALICE is locked, then BOB and CAROL fail once each
+	m.Failed(ctx, "ALICE", "10.0.0.1")
+	m.Failed(ctx, "ALICE", "10.0.0.1")
+	clock.Add(time.Second)
+	m.Failed(ctx, "BOB", "10.0.0.1")
+	clock.Add(time.Second)
+	m.Failed(ctx, "CAROL", "10.0.0.1")
+
+	// The limiter is full, and nothing can be pruned, so the oldest key is forgotten
+	m.Failed(ctx, "DAVE", "10.0.0.1")
+	accounts, _ := m.Len()
+	assert.Equal(t, 3, accounts)
+	assert.NoError(t, m.Allow(ctx, "ALICE", "10.0.0.1"), "ALICE was forgotten")
+
+	// Once BOB and CAROL's failures have decayed, they're pruned before anything is forgotten
+	m.Failed(ctx, "DAVE", "10.0.0.1")
+	clock.Add(time.Minute)
+	m.Failed(ctx, "ERIN", "10.0.0.1")
+	accounts, _ = m.Len()
+	assert.Equal(t, 2, accounts)
+	assert.Error(t, m.Allow(ctx, "DAVE", "10.0.0.1"), "DAVE is locked and was kept")
+}
@@ -0,0 +1,356 @@
+// Package patch serves client patches over the auth server's patch transfer (XFER) protocol.
+//
+// When a client's build is too old, the server can send [authproto.XferInitiate] describing an
+// MPQ patch. The client answers with XFER_ACCEPT, XFER_RESUME or XFER_CANCEL, and the server then
+// streams the file in XFER_DATA chunks. Once the download completes, the client applies the patch
+// and restarts.
+package patch
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/kangaroux/go-wow-srp6/authproto"
+)
+
+const (
+	// DefaultChunkSize is the size of each XFER_DATA chunk if [Patch.ChunkSize] is not set.
+	DefaultChunkSize = 4096
+
+	// transferFilename is the filename the client expects for patches.
+	transferFilename = "Patch"
+)
+
+var (
+	ErrNotFound      = errors.New("srp/patch: no patch exists for this build and locale")
+	ErrCanceled      = errors.New("srp/patch: client canceled the transfer")
+	ErrInvalidOffset = errors.New("srp/patch: resume offset is past the end of the file")
+)
+
+// Store looks up patches in a directory. Patches are named after the build and locale they
+// apply to, e.g. "5875enUS.mpq" patches a 1.12.1 enUS client. Store caches the MD5 of each patch
+// and is safe to use concurrently.
+type Store struct {
+	Dir string
+
+	mu    sync.Mutex
+	cache map[string]cachedHash
+}
+
+type cachedHash struct {
+	size    int64
+	modTime time.Time
+	md5     []byte
+}
+
+// NewStore returns a store that looks up patches in dir.
+func NewStore(dir string) *Store {
+	return &Store{Dir: dir}
+}
+
+// Open returns the patch for build and locale. If the patch does not exist, Open returns
+// ErrNotFound. The caller must close the patch.
+func (s *Store) Open(build uint16, locale string) (*Patch, error) {
+	if !validLocale(locale) {
+		return nil, ErrNotFound
+	}
+
+	path := filepath.Join(s.Dir, fmt.Sprintf("%d%s.mpq", build, locale))
+
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	sum, err := s.hash(path, f, info)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &Patch{file: f, size: info.Size(), md5: sum}, nil
+}
+
+// hash returns the MD5 of f, using the cached value if the file has not changed.
+func (s *Store) hash(path string, f *os.File, info os.FileInfo) ([]byte, error) {
+	s.mu.Lock()
+	cached, ok := s.cache[path]
+	s.mu.Unlock()
+
+	if ok && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		return cached.md5, nil
+	}
+
+	h := md5.New()
+	if _, err := io.Copy(h, io.NewSectionReader(f, 0, info.Size())); err != nil {
+		return nil, err
+	}
+	sum := h.Sum(nil)
+
+	s.mu.Lock()
+	if s.cache == nil {
+		s.cache = make(map[string]cachedHash)
+	}
+	s.cache[path] = cachedHash{size: info.Size(), modTime: info.ModTime(), md5: sum}
+	s.mu.Unlock()
+
+	return sum, nil
+}
+
+// Patch is an open patch file.
+type Patch struct {
+	// ChunkSize is the size of each XFER_DATA chunk. Defaults to [DefaultChunkSize].
+	ChunkSize int
+
+	file *os.File
+	size int64
+	md5  []byte
+}
+
+// Size returns the size of the patch in bytes.
+func (p *Patch) Size() int64 {
+	return p.size
+}
+
+// MD5 returns the MD5 of the patch, which the client uses to verify the download.
+func (p *Patch) MD5() []byte {
+	return p.md5
+}
+
+// Initiate returns the packet which offers the patch to the client.
+func (p *Patch) Initiate() *authproto.XferInitiate {
+	return &authproto.XferInitiate{
+		Filename: transferFilename,
+		FileSize: uint64(p.size),
+		FileMD5:  p.md5,
+	}
+}
+
+// Conn is the connection to the client, usually a net.Conn. Transfer uses the deadlines to
+// interrupt blocked reads and writes.
+type Conn interface {
+	io.ReadWriter
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+}
+
+// Transfer offers the patch to the client and streams it once the client accepts. If the client
+// resumes a previous download, only the remainder of the file is sent. If the client declines,
+// Transfer returns ErrCanceled.
+//
+// While the file is being sent, Transfer keeps reading conn. If the client sends XFER_CANCEL,
+// Transfer stops and returns ErrCanceled, and if it sends XFER_RESUME, the file is sent again
+// from the new offset. If ctx is done, blocked reads and writes are interrupted and Transfer
+// returns ctx.Err(). Transfer stops reading before it returns and clears the deadlines of conn,
+// so conn can be used for the next packet.
+func (p *Patch) Transfer(ctx context.Context, conn Conn) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	stopInterrupting := interrupt(ctx, conn)
+	defer stopInterrupting()
+
+	err := p.transfer(ctx, conn, cancel)
+
+	// The error is from an interrupted read or write, so return why it was interrupted instead
+	if err != nil && ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	return err
+}
+
+// transfer is Transfer, without the interruptions.
+func (p *Patch) transfer(ctx context.Context, conn Conn, cancel context.CancelCauseFunc) error {
+	data, err := p.Initiate().MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Write(data); err != nil {
+		return err
+	}
+
+	offset, err := readReply(conn)
+	if err != nil {
+		return err
+	}
+
+	resume := make(chan uint64, 1)
+	stopWatching := watchClient(conn, resume, cancel)
+	defer stopWatching()
+
+	return p.send(ctx, conn, offset, resume)
+}
+
+// Send writes the patch to w as XFER_DATA chunks, starting at offset. Send stops between chunks
+// when ctx is done.
+func (p *Patch) Send(ctx context.Context, w io.Writer, offset uint64) error {
+	return p.send(ctx, w, offset, nil)
+}
+
+// send is Send, but restarts from each offset received on resume.
+func (p *Patch) send(ctx context.Context, w io.Writer, offset uint64, resume <-chan uint64) error {
+	chunkSize := p.ChunkSize
+	if chunkSize <= 0 || chunkSize > 0xFFFF {
+		chunkSize = DefaultChunkSize
+	}
+	chunk := make([]byte, chunkSize)
+
+	var r *io.SectionReader
+	seek := func(offset uint64) error {
+		if offset > uint64(p.size) {
+			return ErrInvalidOffset
+		}
+		r = io.NewSectionReader(p.file, int64(offset), p.size-int64(offset))
+		return nil
+	}
+	if err := seek(offset); err != nil {
+		return err
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		select {
+		case offset := <-resume:
+			if err := seek(offset); err != nil {
+				return err
+			}
+		default:
+		}
+
+		n, err := io.ReadFull(r, chunk)
+		if n > 0 {
+			data, _ := (&authproto.XferData{Data: chunk[:n]}).MarshalBinary()
+			if _, err := w.Write(data); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// Close closes the patch file.
+func (p *Patch) Close() error {
+	return p.file.Close()
+}
+
+// validLocale returns true if locale looks like a client locale, e.g. "enUS". This also stops
+// the locale from being used to escape the patch directory.
+func validLocale(locale string) bool {
+	if len(locale) != 4 {
+		return false
+	}
+	for _, c := range locale {
+		if !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// watchClient reads the client's packets from conn while the file is being sent. Each resume
+// offset is sent on resume, replacing any offset that hasn't been used yet. Once the client
+// cancels or sends an invalid packet, watchClient calls cancel with the reason. If conn has no
+// more packets, the transfer carries on, and any problem with the connection is left for the
+// writes to find. The returned func interrupts the read in progress and waits for it to stop.
+func watchClient(conn Conn, resume chan uint64, cancel context.CancelCauseFunc) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			offset, err := readReply(conn)
+			if err == io.EOF {
+				return
+			} else if err != nil {
+				cancel(err)
+				return
+			}
+
+			select {
+			case <-resume:
+			default:
+			}
+			resume <- offset
+		}
+	}()
+
+	return func() {
+		// Any time in the past interrupts a blocked read
+		conn.SetReadDeadline(time.Unix(1, 0))
+		<-done
+	}
+}
+
+// interrupt interrupts blocked reads and writes on conn once ctx is done. The returned func stops
+// interrupting and clears the deadlines.
+func interrupt(ctx context.Context, conn Conn) (stop func()) {
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		select {
+		case <-ctx.Done():
+			conn.SetReadDeadline(time.Unix(1, 0))
+			conn.SetWriteDeadline(time.Unix(1, 0))
+		case <-stopCh:
+		}
+	}()
+
+	return func() {
+		close(stopCh)
+		<-done
+		conn.SetReadDeadline(time.Time{})
+		conn.SetWriteDeadline(time.Time{})
+	}
+}
+
+// readReply reads the client's reply to XFER_INITIATE and returns the offset to start sending
+// from.
+func readReply(r io.Reader) (uint64, error) {
+	var opcode [1]byte
+	if _, err := io.ReadFull(r, opcode[:]); err != nil {
+		return 0, err
+	}
+
+	// Put the opcode back so the packet can be decoded as a whole
+	r = io.MultiReader(bytes.NewReader(opcode[:]), r)
+
+	switch opcode[0] {
+	case authproto.OpcodeXferAccept:
+		return 0, (&authproto.XferAccept{}).Decode(r)
+	case authproto.OpcodeXferResume:
+		resume := &authproto.XferResume{}
+		err := resume.Decode(r)
+		return resume.Offset, err
+	case authproto.OpcodeXferCancel:
+		if err := (&authproto.XferCancel{}).Decode(r); err != nil {
+			return 0, err
+		}
+		return 0, ErrCanceled
+	default:
+		return 0, authproto.ErrInvalidOpcode
+	}
+}
@@ -0,0 +1,282 @@
+package patch
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kangaroux/go-wow-srp6/authproto"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestStore(t *testing.T, contents []byte) *Store {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "5875enUS.mpq"), contents, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return NewStore(dir)
+}
+
+// bufferConn is a Conn which reads the client's packets from r and writes to w. Reading r never
+// blocks, so the deadlines do nothing.
+type bufferConn struct {
+	io.Reader
+	io.Writer
+}
+
+func (bufferConn) SetReadDeadline(time.Time) error  { return nil }
+func (bufferConn) SetWriteDeadline(time.Time) error { return nil }
+
+// readChunks decodes XFER_DATA packets from r until it's empty and returns the joined data.
+func readChunks(t *testing.T, r *bytes.Reader) []byte {
+	var data []byte
+	for r.Len() > 0 {
+		chunk := &authproto.XferData{}
+		if !assert.NoError(t, chunk.Decode(r)) {
+			break
+		}
+		assert.LessOrEqual(t, len(chunk.Data), 100)
+		data = append(data, chunk.Data...)
+	}
+	return data
+}
+
+func TestOpen(t *testing.T) {
+	contents := bytes.Repeat([]byte("patch"), 1000)
+	store := newTestStore(t, contents)
+	sum := md5.Sum(contents)
+
+	t.Run("existing patch", func(t *testing.T) {
+		p, err := store.Open(5875, "enUS")
+		assert.NoError(t, err)
+		defer p.Close()
+
+		assert.Equal(t, int64(len(contents)), p.Size())
+		assert.Equal(t, sum[:], p.MD5())
+		assert.Equal(t, &authproto.XferInitiate{
+			Filename: "Patch",
+			FileSize: uint64(len(contents)),
+			FileMD5:  sum[:],
+		}, p.Initiate())
+	})
+
+	t.Run("missing patch", func(t *testing.T) {
+		_, err := store.Open(5875, "deDE")
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = store.Open(6005, "enUS")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("invalid locale", func(t *testing.T) {
+		_, err := store.Open(5875, "../x")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestTransfer(t *testing.T) {
+	contents := make([]byte, 1050)
+	for i := range contents {
+		contents[i] = byte(i)
+	}
+	store := newTestStore(t, contents)
+
+	transfer := func(reply []byte) (*bytes.Reader, error) {
+		p, err := store.Open(5875, "enUS")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer p.Close()
+		p.ChunkSize = 100
+
+		out := &bytes.Buffer{}
+		err = p.Transfer(context.Background(), bufferConn{bytes.NewReader(reply), out})
+
+		r := bytes.NewReader(out.Bytes())
+		initiate := &authproto.XferInitiate{}
+		assert.NoError(t, initiate.Decode(r))
+		assert.Equal(t, p.Initiate(), initiate)
+		return r, err
+	}
+
+	t.Run("accept", func(t *testing.T) {
+		r, err := transfer([]byte{authproto.OpcodeXferAccept})
+		assert.NoError(t, err)
+		assert.Equal(t, contents, readChunks(t, r))
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		reply, _ := (&authproto.XferResume{Offset: 250}).MarshalBinary()
+		r, err := transfer(reply)
+		assert.NoError(t, err)
+		assert.Equal(t, contents[250:], readChunks(t, r))
+	})
+
+	t.Run("resume at end", func(t *testing.T) {
+		reply, _ := (&authproto.XferResume{Offset: uint64(len(contents))}).MarshalBinary()
+		r, err := transfer(reply)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, r.Len())
+	})
+
+	t.Run("resume past end", func(t *testing.T) {
+		reply, _ := (&authproto.XferResume{Offset: uint64(len(contents)) + 1}).MarshalBinary()
+		_, err := transfer(reply)
+		assert.ErrorIs(t, err, ErrInvalidOffset)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		r, err := transfer([]byte{authproto.OpcodeXferCancel})
+		assert.ErrorIs(t, err, ErrCanceled)
+		assert.Equal(t, 0, r.Len())
+	})
+
+	t.Run("invalid reply", func(t *testing.T) {
+		_, err := transfer([]byte{authproto.OpcodeXferData})
+		assert.ErrorIs(t, err, authproto.ErrInvalidOpcode)
+
+		_, err = transfer([]byte{authproto.OpcodeXferResume, 1})
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+
+	t.Run("context canceled", func(t *testing.T) {
+		p, _ := store.Open(5875, "enUS")
+		defer p.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.ErrorIs(t, p.Send(ctx, io.Discard, 0), context.Canceled)
+	})
+}
+
+// transferOverPipe runs Transfer over net.Pipe. client is called with the client side of the
+// connection once the transfer has started.
+func transferOverPipe(ctx context.Context, p *Patch, client func(conn net.Conn)) error {
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+
+	go client(clientConn)
+	return p.Transfer(ctx, serverConn)
+}
+
+func TestTransferCanceledMidway(t *testing.T) {
+	contents := make([]byte, 10000)
+	store := newTestStore(t, contents)
+	p, _ := store.Open(5875, "enUS")
+	defer p.Close()
+	p.ChunkSize = 100
+
+	chunks := make(chan int, 1)
+	err := transferOverPipe(context.Background(), p, func(conn net.Conn) {
+		assert.NoError(t, (&authproto.XferInitiate{}).Decode(conn))
+		conn.Write([]byte{authproto.OpcodeXferAccept})
+
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, (&authproto.XferData{}).Decode(conn))
+		}
+		chunks <- 3
+
+		// Stop reading, so the server is blocked writing the next chunk when the cancel arrives
+		conn.Write([]byte{authproto.OpcodeXferCancel})
+	})
+
+	assert.ErrorIs(t, err, ErrCanceled)
+	assert.Equal(t, 3, <-chunks)
+}
+
+func TestTransferResumedMidway(t *testing.T) {
+	contents := make([]byte, 1000)
+	for i := range contents {
+		contents[i] = byte(i)
+	}
+	store := newTestStore(t, contents)
+	p, _ := store.Open(5875, "enUS")
+	defer p.Close()
+	p.ChunkSize = 100
+
+	received := make(chan int, 1)
+	err := transferOverPipe(context.Background(), p, func(conn net.Conn) {
+		assert.NoError(t, (&authproto.XferInitiate{}).Decode(conn))
+		conn.Write([]byte{authproto.OpcodeXferAccept})
+
+		first := &authproto.XferData{}
+		assert.NoError(t, first.Decode(conn))
+		assert.Equal(t, contents[:100], first.Data)
+
+		// Ask for the end of the file. The server is blocked writing the next chunk, which is
+		// from the old offset. Wait before reading it, so the server has handled the resume by
+		// the time it sends the chunk after that.
+		resume, _ := (&authproto.XferResume{Offset: 900}).MarshalBinary()
+		conn.Write(resume)
+		time.Sleep(50 * time.Millisecond)
+
+		count := 1
+		for {
+			chunk := &authproto.XferData{}
+			if !assert.NoError(t, chunk.Decode(conn)) {
+				break
+			}
+			count++
+			if bytes.Equal(chunk.Data, contents[900:]) {
+				break
+			}
+		}
+		received <- count
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, <-received, "the file was not resumed")
+}
+
+func TestTransferNoReply(t *testing.T) {
+	store := newTestStore(t, make([]byte, 1000))
+	p, _ := store.Open(5875, "enUS")
+	defer p.Close()
+
+	// The client never answers the offer
+	ctx, cancel := context.WithCancel(context.Background())
+	err := transferOverPipe(ctx, p, func(conn net.Conn) {
+		assert.NoError(t, (&authproto.XferInitiate{}).Decode(conn))
+		cancel()
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestTransferStopsReading(t *testing.T) {
+	contents := make([]byte, 1000)
+	store := newTestStore(t, contents)
+	p, _ := store.Open(5875, "enUS")
+	defer p.Close()
+	p.ChunkSize = 100
+
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+
+	done := make(chan struct{})
+	go func() {
+		assert.NoError(t, (&authproto.XferInitiate{}).Decode(clientConn))
+		clientConn.Write([]byte{authproto.OpcodeXferAccept})
+		for i := 0; i < 10; i++ {
+			assert.NoError(t, (&authproto.XferData{}).Decode(clientConn))
+		}
+
+		// The next packet is sent once the transfer is over, and must not be read by it
+		<-done
+		clientConn.Write([]byte{authproto.OpcodeXferCancel})
+	}()
+
+	assert.NoError(t, p.Transfer(context.Background(), serverConn))
+	close(done)
+
+	var next [1]byte
+	_, err := io.ReadFull(serverConn, next[:])
+	assert.NoError(t, err)
+	assert.Equal(t, byte(authproto.OpcodeXferCancel), next[0])
+}
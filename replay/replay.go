@@ -0,0 +1,207 @@
+// Package replay remembers recently used client data, such as reconnect proof data and world
+// proof seeds, and rejects it if it's used again. [Guard] implements [authserver.ReplayGuard].
+//
+// Reconnect and world proofs are computed from the session key, which stays the same for the
+// whole session, so the guard stops a captured proof from being replayed while the session key is
+// still valid.
+//
+// The guard remembers each entry for TTL, at most PerUsername entries for each account, and at
+// most Size entries in total. When an account reaches PerUsername, its own oldest entry is
+// forgotten, so one account can't push out another account's entries. When the guard is full,
+// the oldest entry of any account is forgotten. A forgotten entry can be replayed until its TTL
+// would have run out, so Size should be large enough to hold every entry for the full TTL.
+package replay
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kangaroux/go-wow-srp6/authproto"
+)
+
+const (
+	DefaultSize        = 65536
+	DefaultPerUsername = 64
+	DefaultTTL         = time.Hour
+)
+
+// ErrReplay is returned by [Guard.Check] when the data has already been seen. Its login result is
+// [authproto.LoginFailIncorrectPassword].
+var ErrReplay error = replayError{}
+
+type replayError struct{}
+
+func (replayError) Error() string {
+	return "srp/replay: data has already been used"
+}
+
+func (replayError) LoginResult() authproto.LoginResult {
+	return authproto.LoginFailIncorrectPassword
+}
+
+// Guard remembers recently seen data. It is safe to use concurrently.
+type Guard struct {
+	// Size is the maximum number of entries. Defaults to DefaultSize.
+	Size int
+
+	// PerUsername is the maximum number of entries for one username. Defaults to
+	// DefaultPerUsername.
+	PerUsername int
+
+	// TTL is how long each entry is remembered. Defaults to DefaultTTL.
+	TTL time.Duration
+
+	// Now returns the current time. Defaults to [time.Now].
+	Now func() time.Time
+
+	mu sync.Mutex
+
+	// entries is ordered from oldest to newest. Every entry has the same TTL, so it's also in
+	// expiry order.
+	entries *list.List
+	seen    map[[sha256.Size]byte]*list.Element
+
+	// users holds the entries of each username, from oldest to newest. The values are elements of
+	// entries.
+	users map[string]*list.List
+}
+
+type entry struct {
+	key      [sha256.Size]byte
+	expires  time.Time
+	username string
+
+	// userEl is the entry's element in users
+	userEl *list.Element
+}
+
+// Check returns ErrReplay if username has already used data, otherwise it remembers it. Each
+// argument is hashed separately, so ("ab", "c") and ("a", "bc") are different.
+func (g *Guard) Check(ctx context.Context, username string, data ...[]byte) error {
+	username = strings.ToUpper(username)
+	key := hashKey(username, data)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.seen == nil {
+		g.entries = list.New()
+		g.seen = make(map[[sha256.Size]byte]*list.Element)
+		g.users = make(map[string]*list.List)
+	}
+
+	now := g.now()
+	g.expire(now)
+
+	if _, ok := g.seen[key]; ok {
+		return ErrReplay
+	}
+
+	user := g.users[username]
+	if user == nil {
+		user = list.New()
+		g.users[username] = user
+	}
+
+	// Make room in the account's own entries first, so other accounts are only affected once the
+	// whole guard is full
+	for user.Len() >= g.perUsername() {
+		g.remove(user.Front().Value.(*list.Element))
+	}
+	for g.entries.Len() >= g.size() {
+		g.remove(g.entries.Front())
+	}
+
+	e := &entry{key: key, expires: now.Add(g.ttl()), username: username}
+	el := g.entries.PushBack(e)
+	e.userEl = user.PushBack(el)
+	g.seen[key] = el
+	return nil
+}
+
+// Len returns the number of entries, including expired entries which haven't been removed yet.
+func (g *Guard) Len() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.entries == nil {
+		return 0
+	}
+	return g.entries.Len()
+}
+
+// expire removes the expired entries from the front of the list.
+func (g *Guard) expire(now time.Time) {
+	for el := g.entries.Front(); el != nil; el = g.entries.Front() {
+		if now.Before(el.Value.(*entry).expires) {
+			return
+		}
+		g.remove(el)
+	}
+}
+
+func (g *Guard) remove(el *list.Element) {
+	e := el.Value.(*entry)
+	delete(g.seen, e.key)
+	g.entries.Remove(el)
+
+	user := g.users[e.username]
+	user.Remove(e.userEl)
+	if user.Len() == 0 {
+		delete(g.users, e.username)
+	}
+}
+
+func (g *Guard) size() int {
+	if g.Size > 0 {
+		return g.Size
+	}
+	return DefaultSize
+}
+
+func (g *Guard) perUsername() int {
+	if g.PerUsername > 0 {
+		return g.PerUsername
+	}
+	return DefaultPerUsername
+}
+
+func (g *Guard) ttl() time.Duration {
+	if g.TTL > 0 {
+		return g.TTL
+	}
+	return DefaultTTL
+}
+
+func (g *Guard) now() time.Time {
+	if g.Now != nil {
+		return g.Now()
+	}
+	return time.Now()
+}
+
+// hashKey returns the key for the normalized username and data. Each field is prefixed with its
+// length.
+func hashKey(username string, data [][]byte) [sha256.Size]byte {
+	h := sha256.New()
+	var size [4]byte
+
+	binary.LittleEndian.PutUint32(size[:], uint32(len(username)))
+	h.Write(size[:])
+	h.Write([]byte(username))
+
+	for _, d := range data {
+		binary.LittleEndian.PutUint32(size[:], uint32(len(d)))
+		h.Write(size[:])
+		h.Write(d)
+	}
+
+	var key [sha256.Size]byte
+	h.Sum(key[:0])
+	return key
+}
@@ -0,0 +1,97 @@
+package replay
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kangaroux/go-wow-srp6/authproto"
+	"github.com/kangaroux/go-wow-srp6/authserver"
+	"github.com/stretchr/testify/assert"
+)
+
+var _ authserver.ReplayGuard = (*Guard)(nil)
+
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	g := &Guard{}
+
+	assert.NoError(t, g.Check(ctx, "ALICE", []byte("seed")))
+	assert.ErrorIs(t, g.Check(ctx, "ALICE", []byte("seed")), ErrReplay)
+	assert.ErrorIs(t, g.Check(ctx, "alice", []byte("seed")), ErrReplay, "usernames are normalized")
+	assert.Equal(t, authproto.LoginFailIncorrectPassword, authproto.ResultFromError(ErrReplay))
+
+	// Data is per account
+	assert.NoError(t, g.Check(ctx, "BOB", []byte("seed")))
+
+	// Fields can't be shifted into each other
+	assert.NoError(t, g.Check(ctx, "ALICE", []byte("se"), []byte("ed")))
+	assert.NoError(t, g.Check(ctx, "ALICE", []byte("s"), []byte("eed")))
+	assert.NoError(t, g.Check(ctx, "ALIC", []byte("Eseed")))
+
+	assert.Equal(t, 5, g.Len())
+}
+
+func TestTTL(t *testing.T) {
+	ctx := context.Background()
+	clock := &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	g := &Guard{TTL: time.Minute, Now: clock.Now}
+
+	assert.NoError(t, g.Check(ctx, "ALICE", []byte{1}))
+	clock.now = clock.now.Add(30 * time.Second)
+	assert.NoError(t, g.Check(ctx, "ALICE", []byte{2}))
+
+	clock.now = clock.now.Add(29 * time.Second)
+	assert.ErrorIs(t, g.Check(ctx, "ALICE", []byte{1}), ErrReplay)
+
+	// The first entry expires, the second doesn't
+	clock.now = clock.now.Add(time.Second)
+	assert.NoError(t, g.Check(ctx, "ALICE", []byte{1}))
+	assert.ErrorIs(t, g.Check(ctx, "ALICE", []byte{2}), ErrReplay)
+	assert.Equal(t, 2, g.Len())
+}
+
+func TestSize(t *testing.T) {
+	ctx := context.Background()
+	g := &Guard{Size: 3}
+
+	for i := byte(0); i < 10; i++ {
+		assert.NoError(t, g.Check(ctx, "ALICE", []byte{i}))
+		assert.LessOrEqual(t, g.Len(), 3)
+	}
+
+	// The oldest entries were forgotten
+	assert.NoError(t, g.Check(ctx, "ALICE", []byte{0}))
+	assert.ErrorIs(t, g.Check(ctx, "ALICE", []byte{9}), ErrReplay)
+}
+
+func TestPerUsername(t *testing.T) {
+	ctx := context.Background()
+	g := &Guard{Size: 10, PerUsername: 3}
+
+	assert.NoError(t, g.Check(ctx, "ALICE", []byte("seed")))
+
+	// MALLORY reconnects over and over, but only pushes out their own entries
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, g.Check(ctx, "MALLORY", []byte{byte(i)}))
+	}
+	assert.Equal(t, 4, g.Len())
+	assert.ErrorIs(t, g.Check(ctx, "alice", []byte("seed")), ErrReplay)
+	assert.ErrorIs(t, g.Check(ctx, "MALLORY", []byte{99}), ErrReplay)
+	assert.NoError(t, g.Check(ctx, "MALLORY", []byte{0}))
+
+	// Once the guard is full, the oldest entry of any account is forgotten
+	for i := 0; i < 7; i++ {
+		assert.NoError(t, g.Check(ctx, string(rune('A'+i)), []byte("seed")))
+	}
+	assert.Equal(t, 10, g.Len())
+	assert.NoError(t, g.Check(ctx, "ALICE", []byte("seed")))
+}
@@ -0,0 +1,215 @@
+// Package seal encrypts verifiers and session keys at rest with AES-256-GCM.
+//
+// A sealed value is bound to the username and to what it is (a verifier or a session key) using
+// associated data, so a sealed value can't be copied to another account or used as another kind
+// of secret. Without the master key, a database dump of sealed verifiers can't be used to brute
+// force passwords.
+//
+// Each master key has an ID which is stored with the sealed value. New values are always sealed
+// with the current key, and older keys can be added to the [Keyring] to open values sealed before
+// a rotation. Use [Keyring.Reseal] to move old values to the current key.
+//
+// The sealed format is:
+//
+//	key ID (4 bytes, little endian) | nonce (12 bytes) | ciphertext | tag (16 bytes)
+package seal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"sync"
+
+	srp "github.com/kangaroux/go-wow-srp6"
+)
+
+const (
+	// KeySize is the size of a master key (AES-256).
+	KeySize = 32
+
+	keyIDSize = 4
+	nonceSize = 12
+	tagSize   = 16
+
+	// Overhead is how many bytes sealing adds to the plaintext.
+	Overhead = keyIDSize + nonceSize + tagSize
+
+	// SealedVerifierSize is the size of a verifier sealed with [Keyring.SealVerifier]. The stores
+	// in accountstore and the shadow file accept verifiers of this size as well as plain ones.
+	SealedVerifierSize = srp.VerifierSize + Overhead
+)
+
+var (
+	ErrInvalidKeySize = errors.New("srp/seal: master key must be 32 bytes")
+	ErrDuplicateKeyID = errors.New("srp/seal: key ID already in use")
+	ErrUnknownKeyID   = errors.New("srp/seal: unknown key ID")
+	ErrInvalidSealed  = errors.New("srp/seal: sealed value is too short")
+	ErrOpenFailed     = errors.New("srp/seal: sealed value could not be opened")
+)
+
+// Purpose is what a sealed value is used for. A value sealed for one purpose can't be opened for
+// another. Purposes must not contain a null byte.
+type Purpose string
+
+const (
+	PurposeVerifier   Purpose = "srp6 verifier"
+	PurposeSessionKey Purpose = "srp6 session key"
+)
+
+// Keyring holds the master keys. It is safe to use concurrently.
+type Keyring struct {
+	mu      sync.RWMutex
+	current uint32
+	keys    map[uint32]cipher.AEAD
+}
+
+// NewKeyring returns a keyring which seals values with key. The key should be 32 bytes of
+// cryptographically secure random data ([crypto/rand]).
+func NewKeyring(id uint32, key []byte) (*Keyring, error) {
+	k := &Keyring{keys: make(map[uint32]cipher.AEAD)}
+	if err := k.AddKey(id, key); err != nil {
+		return nil, err
+	}
+	k.current = id
+	return k, nil
+}
+
+// AddKey adds a key which can be used to open values. The key doesn't become the current key, use
+// [Keyring.SetCurrent] once the key is available everywhere the values are opened.
+func (k *Keyring) AddKey(id uint32, key []byte) error {
+	if len(key) != KeySize {
+		return ErrInvalidKeySize
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if _, ok := k.keys[id]; ok {
+		return ErrDuplicateKeyID
+	}
+	k.keys[id] = aead
+	return nil
+}
+
+// SetCurrent changes the key used to seal new values. The key must have been added already.
+func (k *Keyring) SetCurrent(id uint32) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if _, ok := k.keys[id]; !ok {
+		return ErrUnknownKeyID
+	}
+	k.current = id
+	return nil
+}
+
+// Current returns the ID of the key used to seal new values.
+func (k *Keyring) Current() uint32 {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.current
+}
+
+// Seal encrypts plaintext with the current key, bound to purpose and username.
+func (k *Keyring) Seal(purpose Purpose, username string, plaintext []byte) ([]byte, error) {
+	k.mu.RLock()
+	id := k.current
+	aead := k.keys[id]
+	k.mu.RUnlock()
+
+	sealed := make([]byte, keyIDSize+nonceSize, Overhead+len(plaintext))
+	binary.LittleEndian.PutUint32(sealed, id)
+
+	nonce := sealed[keyIDSize:]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(sealed, nonce, plaintext, additionalData(sealed[:keyIDSize], purpose, username)), nil
+}
+
+// Open decrypts a value sealed with [Keyring.Seal]. If the value was sealed for another purpose or
+// username, or has been modified, Open returns ErrOpenFailed.
+func (k *Keyring) Open(purpose Purpose, username string, sealed []byte) ([]byte, error) {
+	if len(sealed) < Overhead {
+		return nil, ErrInvalidSealed
+	}
+
+	k.mu.RLock()
+	aead, ok := k.keys[binary.LittleEndian.Uint32(sealed)]
+	k.mu.RUnlock()
+
+	if !ok {
+		return nil, ErrUnknownKeyID
+	}
+
+	nonce := sealed[keyIDSize : keyIDSize+nonceSize]
+	ciphertext := sealed[keyIDSize+nonceSize:]
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData(sealed[:keyIDSize], purpose, username))
+	if err != nil {
+		return nil, ErrOpenFailed
+	}
+	return plaintext, nil
+}
+
+// Reseal opens a sealed value and seals it again with the current key. If the value is already
+// sealed with the current key, it is returned unchanged and changed is false.
+func (k *Keyring) Reseal(purpose Purpose, username string, sealed []byte) (resealed []byte, changed bool, err error) {
+	plaintext, err := k.Open(purpose, username, sealed)
+	if err != nil {
+		return nil, false, err
+	}
+	if binary.LittleEndian.Uint32(sealed) == k.Current() {
+		return sealed, false, nil
+	}
+
+	resealed, err = k.Seal(purpose, username, plaintext)
+	if err != nil {
+		return nil, false, err
+	}
+	return resealed, true, nil
+}
+
+// SealVerifier seals a password verifier for username.
+func (k *Keyring) SealVerifier(username string, verifier []byte) ([]byte, error) {
+	return k.Seal(PurposeVerifier, username, verifier)
+}
+
+// OpenVerifier opens a password verifier sealed with [Keyring.SealVerifier].
+func (k *Keyring) OpenVerifier(username string, sealed []byte) ([]byte, error) {
+	return k.Open(PurposeVerifier, username, sealed)
+}
+
+// SealSessionKey seals a session key for username.
+func (k *Keyring) SealSessionKey(username string, sessionKey []byte) ([]byte, error) {
+	return k.Seal(PurposeSessionKey, username, sessionKey)
+}
+
+// OpenSessionKey opens a session key sealed with [Keyring.SealSessionKey].
+func (k *Keyring) OpenSessionKey(username string, sealed []byte) ([]byte, error) {
+	return k.Open(PurposeSessionKey, username, sealed)
+}
+
+// additionalData returns the associated data: the key ID, the purpose, and the normalized
+// username. The purpose is null terminated, so the fields can't be shifted into each other.
+func additionalData(keyID []byte, purpose Purpose, username string) []byte {
+	ad := make([]byte, 0, len(keyID)+len(purpose)+1+len(username))
+	ad = append(ad, keyID...)
+	ad = append(ad, purpose...)
+	ad = append(ad, 0)
+	ad = append(ad, strings.ToUpper(username)...)
+	return ad
+}
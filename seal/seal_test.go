@@ -0,0 +1,113 @@
+package seal
+
+import (
+	"bytes"
+	"testing"
+
+	srp "github.com/kangaroux/go-wow-srp6"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testKey1 = bytes.Repeat([]byte{0x01}, KeySize)
+	testKey2 = bytes.Repeat([]byte{0x02}, KeySize)
+	testSalt = bytes.Repeat([]byte{0x5A}, srp.SaltSize)
+
+	testVerifier = srp.PasswordVerifier("ALICE", "PASSWORD", testSalt)
+)
+
+func mustNewKeyring(id uint32, key []byte) *Keyring {
+	k, err := NewKeyring(id, key)
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
+
+func TestSealOpen(t *testing.T) {
+	k := mustNewKeyring(1, testKey1)
+
+	sealed, err := k.SealVerifier("alice", testVerifier)
+	assert.NoError(t, err)
+	assert.Len(t, sealed, len(testVerifier)+Overhead)
+	assert.Equal(t, []byte{1, 0, 0, 0}, sealed[:4])
+	assert.NotContains(t, string(sealed), string(testVerifier))
+
+	// Usernames are normalized
+	opened, err := k.OpenVerifier("ALICE", sealed)
+	assert.NoError(t, err)
+	assert.Equal(t, testVerifier, opened)
+
+	// Each seal uses a new nonce
+	again, err := k.SealVerifier("alice", testVerifier)
+	assert.NoError(t, err)
+	assert.NotEqual(t, sealed, again)
+}
+
+func TestOpenFails(t *testing.T) {
+	k := mustNewKeyring(1, testKey1)
+	sealed, err := k.SealVerifier("ALICE", testVerifier)
+	assert.NoError(t, err)
+
+	_, err = k.OpenVerifier("BOB", sealed)
+	assert.ErrorIs(t, err, ErrOpenFailed, "another username")
+
+	_, err = k.OpenSessionKey("ALICE", sealed)
+	assert.ErrorIs(t, err, ErrOpenFailed, "another purpose")
+
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(tampered)-1] ^= 1
+	_, err = k.OpenVerifier("ALICE", tampered)
+	assert.ErrorIs(t, err, ErrOpenFailed, "tampered")
+
+	_, err = k.OpenVerifier("ALICE", sealed[:Overhead-1])
+	assert.ErrorIs(t, err, ErrInvalidSealed)
+
+	_, err = mustNewKeyring(1, testKey2).OpenVerifier("ALICE", sealed)
+	assert.ErrorIs(t, err, ErrOpenFailed, "another key with the same ID")
+
+	_, err = mustNewKeyring(2, testKey1).OpenVerifier("ALICE", sealed)
+	assert.ErrorIs(t, err, ErrUnknownKeyID)
+}
+
+func TestRotation(t *testing.T) {
+	k := mustNewKeyring(1, testKey1)
+	sealed, err := k.SealSessionKey("ALICE", testVerifier)
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, k.AddKey(1, testKey2), ErrDuplicateKeyID)
+	assert.ErrorIs(t, k.AddKey(2, testKey2[1:]), ErrInvalidKeySize)
+	assert.ErrorIs(t, k.SetCurrent(2), ErrUnknownKeyID)
+
+	assert.NoError(t, k.AddKey(2, testKey2))
+	assert.NoError(t, k.SetCurrent(2))
+	assert.Equal(t, uint32(2), k.Current())
+
+	// Values sealed with the old key can still be opened
+	opened, err := k.OpenSessionKey("ALICE", sealed)
+	assert.NoError(t, err)
+	assert.Equal(t, testVerifier, opened)
+
+	resealed, changed, err := k.Reseal(PurposeSessionKey, "ALICE", sealed)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, []byte{2, 0, 0, 0}, resealed[:4])
+
+	again, changed, err := k.Reseal(PurposeSessionKey, "ALICE", resealed)
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, resealed, again)
+
+	// Once the old key is gone, only the resealed value can be opened
+	newOnly := mustNewKeyring(2, testKey2)
+	_, err = newOnly.OpenSessionKey("ALICE", sealed)
+	assert.ErrorIs(t, err, ErrUnknownKeyID)
+	opened, err = newOnly.OpenSessionKey("ALICE", resealed)
+	assert.NoError(t, err)
+	assert.Equal(t, testVerifier, opened)
+}
+
+func TestNewKeyringInvalidKey(t *testing.T) {
+	_, err := NewKeyring(1, testKey1[:16])
+	assert.ErrorIs(t, err, ErrInvalidKeySize)
+}
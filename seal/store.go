@@ -0,0 +1,55 @@
+package seal
+
+import (
+	"context"
+
+	"github.com/kangaroux/go-wow-srp6/authserver"
+)
+
+// AccountStore opens the sealed verifiers returned by another [authserver.AccountStore]. The
+// wrapped store must return accounts whose Verifier is a value sealed with
+// [Keyring.SealVerifier]. The stores in accountstore accept sealed verifiers, so they can be
+// wrapped directly.
+type AccountStore struct {
+	Store authserver.AccountStore
+	Keys  *Keyring
+}
+
+func (s *AccountStore) Account(ctx context.Context, username string) (*authserver.Account, error) {
+	account, err := s.Store.Account(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	verifier, err := s.Keys.OpenVerifier(account.Username, account.Verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	a := *account
+	a.Verifier = verifier
+	return &a, nil
+}
+
+// SessionKeyStore seals session keys before saving them to another [authserver.SessionKeyStore],
+// and opens them when they are loaded.
+type SessionKeyStore struct {
+	Store authserver.SessionKeyStore
+	Keys  *Keyring
+}
+
+func (s *SessionKeyStore) SaveSessionKey(ctx context.Context, username string, sessionKey []byte) error {
+	sealed, err := s.Keys.SealSessionKey(username, sessionKey)
+	if err != nil {
+		return err
+	}
+	return s.Store.SaveSessionKey(ctx, username, sealed)
+}
+
+func (s *SessionKeyStore) SessionKey(ctx context.Context, username string) ([]byte, error) {
+	sealed, err := s.Store.SessionKey(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	return s.Keys.OpenSessionKey(username, sealed)
+}
@@ -0,0 +1,67 @@
+package seal
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kangaroux/go-wow-srp6/authserver"
+	"github.com/stretchr/testify/assert"
+)
+
+type testAccounts map[string]*authserver.Account
+
+func (a testAccounts) Account(ctx context.Context, username string) (*authserver.Account, error) {
+	if account, ok := a[username]; ok {
+		return account, nil
+	}
+	return nil, authserver.ErrAccountNotFound
+}
+
+func TestAccountStore(t *testing.T) {
+	ctx := context.Background()
+	k := mustNewKeyring(1, testKey1)
+
+	sealed, err := k.SealVerifier("ALICE", testVerifier)
+	assert.NoError(t, err)
+
+	stored := &authserver.Account{Username: "ALICE", Salt: testSalt, Verifier: sealed}
+	s := &AccountStore{
+		Store: testAccounts{
+			"ALICE": stored,
+			// A verifier copied from another account can't be opened
+			"BOB": {Username: "BOB", Salt: testSalt, Verifier: sealed},
+		},
+		Keys: k,
+	}
+
+	account, err := s.Account(ctx, "ALICE")
+	assert.NoError(t, err)
+	assert.Equal(t, testVerifier, account.Verifier)
+	assert.Equal(t, sealed, stored.Verifier, "stored account is not modified")
+
+	_, err = s.Account(ctx, "BOB")
+	assert.ErrorIs(t, err, ErrOpenFailed)
+
+	_, err = s.Account(ctx, "CAROL")
+	assert.ErrorIs(t, err, authserver.ErrAccountNotFound)
+}
+
+func TestSessionKeyStore(t *testing.T) {
+	ctx := context.Background()
+	underlying := authserver.NewMemorySessionKeyStore()
+	s := &SessionKeyStore{Store: underlying, Keys: mustNewKeyring(1, testKey1)}
+
+	sessionKey := testVerifier[:20]
+	assert.NoError(t, s.SaveSessionKey(ctx, "ALICE", sessionKey))
+
+	raw, err := underlying.SessionKey(ctx, "ALICE")
+	assert.NoError(t, err)
+	assert.Len(t, raw, len(sessionKey)+Overhead)
+
+	got, err := s.SessionKey(ctx, "ALICE")
+	assert.NoError(t, err)
+	assert.Equal(t, sessionKey, got)
+
+	_, err = s.SessionKey(ctx, "BOB")
+	assert.ErrorIs(t, err, authserver.ErrSessionNotFound)
+}
@@ -0,0 +1,231 @@
+// Package shadow reads and writes a line-based credential file, similar to /etc/shadow:
+//
+//	# comment
+//	USERNAME:hex(salt):hex(verifier):flags
+//
+// Usernames are uppercase, as the client sends them. The salt and verifier are the bytes used by
+// [srp.PasswordVerifier], hex encoded. The verifier may also be sealed with
+// [seal.Keyring.SealVerifier]. Flags are the account's security flags as a decimal number.
+// Blank lines and lines starting with # are ignored.
+package shadow
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	srp "github.com/kangaroux/go-wow-srp6"
+	"github.com/kangaroux/go-wow-srp6/authserver"
+	"github.com/kangaroux/go-wow-srp6/builds"
+	"github.com/kangaroux/go-wow-srp6/seal"
+)
+
+var (
+	ErrInvalidFormat   = errors.New("srp/shadow: expected 4 fields separated by ':'")
+	ErrInvalidUsername = errors.New("srp/shadow: invalid username")
+	ErrNotNormalized   = errors.New("srp/shadow: username is not uppercase")
+	ErrInvalidHex      = errors.New("srp/shadow: invalid hex")
+	ErrSaltSize        = errors.New("srp/shadow: wrong salt size")
+	ErrVerifierSize    = errors.New("srp/shadow: wrong verifier size")
+	ErrInvalidFlags    = errors.New("srp/shadow: invalid flags")
+	ErrDuplicateUser   = errors.New("srp/shadow: duplicate username")
+)
+
+const allFlags = builds.SecurityFlagPIN | builds.SecurityFlagMatrixCard | builds.SecurityFlagAuthenticator
+
+// Entry is a single account in the file.
+type Entry struct {
+	Username string
+	Salt     []byte
+	Verifier []byte
+	Flags    builds.SecurityFlag
+}
+
+// NewEntry returns the entry for account.
+func NewEntry(account *authserver.Account) Entry {
+	return Entry{
+		Username: account.Username,
+		Salt:     account.Salt,
+		Verifier: account.Verifier,
+		Flags:    account.SecurityFlags,
+	}
+}
+
+// Account returns the entry as an account.
+func (e Entry) Account() *authserver.Account {
+	return &authserver.Account{
+		Username:      e.Username,
+		Salt:          e.Salt,
+		Verifier:      e.Verifier,
+		SecurityFlags: e.Flags,
+	}
+}
+
+// Validate checks the username, the salt and verifier sizes, and the flags. The verifier may be
+// plain or sealed.
+func (e Entry) Validate() error {
+	if e.Username == "" || strings.ContainsAny(e.Username, ": \t#") {
+		return ErrInvalidUsername
+	}
+	if e.Username != strings.ToUpper(e.Username) {
+		return ErrNotNormalized
+	}
+	if len(e.Salt) != srp.SaltSize {
+		return ErrSaltSize
+	}
+	if len(e.Verifier) != srp.VerifierSize && len(e.Verifier) != seal.SealedVerifierSize {
+		return ErrVerifierSize
+	}
+	if e.Flags&^allFlags != 0 {
+		return ErrInvalidFlags
+	}
+	return nil
+}
+
+// String returns the entry as a line, without the newline.
+func (e Entry) String() string {
+	return e.Username + ":" +
+		hex.EncodeToString(e.Salt) + ":" +
+		hex.EncodeToString(e.Verifier) + ":" +
+		strconv.Itoa(int(e.Flags))
+}
+
+// LineError is an error on a line of the file.
+type LineError struct {
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// Parse reads all entries from r. Parse stops at the first invalid entry and returns a
+// [*LineError]. Use [Lint] to find every problem in a file.
+func Parse(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	var lineErr error
+
+	err := scan(r, func(line int, e Entry, err error) bool {
+		if err != nil {
+			lineErr = &LineError{Line: line, Err: err}
+			return false
+		}
+		entries = append(entries, e)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if lineErr != nil {
+		return nil, lineErr
+	}
+
+	return entries, nil
+}
+
+// Lint reads r and returns every problem found, in line order. The error is only set if reading
+// r failed.
+func Lint(r io.Reader) ([]*LineError, error) {
+	var problems []*LineError
+
+	err := scan(r, func(line int, e Entry, err error) bool {
+		if err != nil {
+			problems = append(problems, &LineError{Line: line, Err: err})
+		}
+		return true
+	})
+
+	return problems, err
+}
+
+// Write writes entries to w, one per line. Write returns an error without writing anything if any
+// entry is invalid or a username appears more than once.
+func Write(w io.Writer, entries []Entry) error {
+	seen := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		if err := e.Validate(); err != nil {
+			return fmt.Errorf("%w: %q", err, e.Username)
+		}
+		if seen[e.Username] {
+			return fmt.Errorf("%w: %q", ErrDuplicateUser, e.Username)
+		}
+		seen[e.Username] = true
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, e := range entries {
+		bw.WriteString(e.String())
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// scan calls fn with each entry in r and its line number. If the line is invalid, the entry may be
+// partially parsed and err is set. Scanning stops when fn returns false.
+func scan(r io.Reader, fn func(line int, e Entry, err error) bool) error {
+	s := bufio.NewScanner(r)
+	seen := make(map[string]int)
+
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		e, err := parseLine(text)
+		if err == nil {
+			err = e.Validate()
+		}
+
+		// Usernames that only differ by case are the same account, so they're duplicates even if
+		// one of them isn't normalized
+		if err == nil || errors.Is(err, ErrNotNormalized) {
+			username := strings.ToUpper(e.Username)
+			if first, ok := seen[username]; ok {
+				err = fmt.Errorf("%w: first seen on line %d", ErrDuplicateUser, first)
+			} else {
+				seen[username] = line
+			}
+		}
+
+		if !fn(line, e, err) {
+			return nil
+		}
+	}
+
+	return s.Err()
+}
+
+func parseLine(line string) (Entry, error) {
+	fields := strings.Split(line, ":")
+	if len(fields) != 4 {
+		return Entry{}, ErrInvalidFormat
+	}
+
+	e := Entry{Username: fields[0]}
+	var err error
+
+	if e.Salt, err = hex.DecodeString(fields[1]); err != nil {
+		return e, fmt.Errorf("%w: salt", ErrInvalidHex)
+	}
+	if e.Verifier, err = hex.DecodeString(fields[2]); err != nil {
+		return e, fmt.Errorf("%w: verifier", ErrInvalidHex)
+	}
+
+	flags, err := strconv.ParseUint(fields[3], 10, 8)
+	if err != nil {
+		return e, ErrInvalidFlags
+	}
+	e.Flags = builds.SecurityFlag(flags)
+
+	return e, nil
+}
@@ -0,0 +1,154 @@
+package shadow
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	srp "github.com/kangaroux/go-wow-srp6"
+	"github.com/kangaroux/go-wow-srp6/builds"
+	"github.com/kangaroux/go-wow-srp6/seal"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testSalt     = bytes.Repeat([]byte{0xAB}, srp.SaltSize)
+	testVerifier = srp.PasswordVerifier("ALICE", "PASSWORD", testSalt)
+	testSaltHex  = strings.Repeat("ab", srp.SaltSize)
+	testVerHex   = strings.Repeat("00", srp.VerifierSize)
+)
+
+func TestWriteParse(t *testing.T) {
+	entries := []Entry{
+		{Username: "ALICE", Salt: testSalt, Verifier: testVerifier},
+		{Username: "BOB", Salt: testSalt, Verifier: testVerifier, Flags: builds.SecurityFlagPIN},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, Write(&buf, entries))
+	assert.Equal(t, entries[0].String()+"\n"+entries[1].String()+"\n", buf.String())
+	assert.True(t, strings.HasPrefix(buf.String(), "ALICE:"+testSaltHex+":"))
+	assert.True(t, strings.HasSuffix(buf.String(), ":1\n"))
+
+	parsed, err := Parse(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, entries, parsed)
+}
+
+func TestSealedVerifier(t *testing.T) {
+	keys, _ := seal.NewKeyring(1, bytes.Repeat([]byte{1}, seal.KeySize))
+	sealed, err := keys.SealVerifier("ALICE", testVerifier)
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	entries := []Entry{{Username: "ALICE", Salt: testSalt, Verifier: sealed}}
+	assert.NoError(t, Write(&buf, entries))
+
+	parsed, err := Parse(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, entries, parsed)
+
+	opened, err := keys.OpenVerifier("ALICE", parsed[0].Verifier)
+	assert.NoError(t, err)
+	assert.Equal(t, testVerifier, opened)
+}
+
+func TestWriteInvalid(t *testing.T) {
+	valid := Entry{Username: "ALICE", Salt: testSalt, Verifier: testVerifier}
+
+	var buf bytes.Buffer
+	assert.ErrorIs(t, Write(&buf, []Entry{valid, {Username: "alice", Salt: testSalt, Verifier: testVerifier}}), ErrNotNormalized)
+	assert.ErrorIs(t, Write(&buf, []Entry{valid, valid}), ErrDuplicateUser)
+	assert.ErrorIs(t, Write(&buf, []Entry{{Username: "BOB", Salt: testSalt[1:], Verifier: testVerifier}}), ErrSaltSize)
+	assert.Zero(t, buf.Len())
+}
+
+func TestParse(t *testing.T) {
+	file := "# accounts\n\nALICE:" + testSaltHex + ":" + testVerHex + ":0\n  \n" +
+		"BOB:" + strings.ToUpper(testSaltHex) + ":" + testVerHex + ":6\n"
+
+	entries, err := Parse(strings.NewReader(file))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, "BOB", entries[1].Username)
+	assert.Equal(t, testSalt, entries[1].Salt)
+	assert.Equal(t, builds.SecurityFlagMatrixCard|builds.SecurityFlagAuthenticator, entries[1].Flags)
+
+	account := entries[1].Account()
+	assert.Equal(t, "BOB", account.Username)
+	assert.Equal(t, entries[1], NewEntry(account))
+}
+
+func TestParseError(t *testing.T) {
+	file := "# accounts\nALICE:" + testSaltHex + ":" + testVerHex + ":0\nBOB:" + testSaltHex + "\n"
+
+	_, err := Parse(strings.NewReader(file))
+	var lineErr *LineError
+	assert.ErrorAs(t, err, &lineErr)
+	assert.Equal(t, 3, lineErr.Line)
+	assert.ErrorIs(t, err, ErrInvalidFormat)
+}
+
+func TestLint(t *testing.T) {
+	lines := []string{
+		"ALICE:" + testSaltHex + ":" + testVerHex + ":0",
+		"bob:" + testSaltHex + ":" + testVerHex + ":0",
+		"CAROL:" + testSaltHex[2:] + ":" + testVerHex + ":0",
+		"DAVE:" + testSaltHex + ":" + testVerHex + "00:0",
+		"ALICE:" + testSaltHex + ":" + testVerHex + ":0",
+		"ERIN:zz:" + testVerHex + ":0",
+		"FRANK:" + testSaltHex + ":" + testVerHex + ":8",
+		"GRACE:" + testSaltHex + ":" + testVerHex + ":x",
+		":" + testSaltHex + ":" + testVerHex + ":0",
+		"HEIDI:" + testSaltHex + ":" + testVerHex,
+		"# IVAN:" + testSaltHex + ":" + testVerHex + ":0",
+	}
+
+	problems, err := Lint(strings.NewReader(strings.Join(lines, "\n")))
+	assert.NoError(t, err)
+
+	expected := []struct {
+		line int
+		err  error
+	}{
+		{2, ErrNotNormalized},
+		{3, ErrSaltSize},
+		{4, ErrVerifierSize},
+		{5, ErrDuplicateUser},
+		{6, ErrInvalidHex},
+		{7, ErrInvalidFlags},
+		{8, ErrInvalidFlags},
+		{9, ErrInvalidUsername},
+		{10, ErrInvalidFormat},
+	}
+
+	if assert.Len(t, problems, len(expected)) {
+		for i, e := range expected {
+			assert.Equal(t, e.line, problems[i].Line)
+			assert.ErrorIs(t, problems[i], e.err)
+		}
+	}
+	assert.Contains(t, problems[3].Error(), "line 5: srp/shadow: duplicate username: first seen on line 1")
+}
+
+func TestLintCaseDuplicates(t *testing.T) {
+	lines := []string{
+		"alice:" + testSaltHex + ":" + testVerHex + ":0",
+		"ALICE:" + testSaltHex + ":" + testVerHex + ":0",
+		"BOB:" + testSaltHex + ":" + testVerHex + ":0",
+		"Bob:" + testSaltHex + ":" + testVerHex + ":0",
+	}
+
+	problems, err := Lint(strings.NewReader(strings.Join(lines, "\n")))
+	assert.NoError(t, err)
+
+	if assert.Len(t, problems, 3) {
+		assert.Equal(t, 1, problems[0].Line)
+		assert.ErrorIs(t, problems[0], ErrNotNormalized)
+		assert.Equal(t, 2, problems[1].Line)
+		assert.ErrorIs(t, problems[1], ErrDuplicateUser)
+		assert.Contains(t, problems[1].Error(), "first seen on line 1")
+		assert.Equal(t, 4, problems[2].Line)
+		assert.ErrorIs(t, problems[2], ErrDuplicateUser)
+	}
+}
@@ -0,0 +1,44 @@
+"""
+Usage:
+$ python3 tbc_header.py > tbc_header.csv
+"""
+
+import hashlib
+import hmac
+
+from gen import rand_hex
+
+SEED = bytes.fromhex("38A78315F8922530719867B18C04E2AA")
+
+
+def encrypt(key: bytes, data: bytes) -> bytes:
+    out = bytearray()
+    index, last = 0, 0
+    for b in data:
+        last = ((b ^ key[index]) + last) & 0xFF
+        index = (index + 1) % len(key)
+        out.append(last)
+    return bytes(out)
+
+
+def decrypt(key: bytes, data: bytes) -> bytes:
+    out = bytearray()
+    index, last = 0, 0
+    for b in data:
+        out.append(((b - last) & 0xFF) ^ key[index])
+        index = (index + 1) % len(key)
+        last = b
+    return bytes(out)
+
+
+for _ in range(100):
+    session_key = rand_hex(40)
+    data = rand_hex(48)
+    key = hmac.new(SEED, bytes.fromhex(session_key), hashlib.sha1).digest()
+    row = [
+        session_key, # session key (40 bytes)
+        data, # headers, encrypted/decrypted as one stream (48 bytes)
+        encrypt(key, bytes.fromhex(data)).hex().upper(), # encrypted data
+        decrypt(key, bytes.fromhex(data)).hex().upper(), # decrypted data
+    ]
+    print(",".join(row))
@@ -0,0 +1,39 @@
+"""
+Usage:
+$ python3 vanilla_header.py > vanilla_header.csv
+"""
+
+from gen import rand_hex
+
+
+def encrypt(key: bytes, data: bytes) -> bytes:
+    out = bytearray()
+    index, last = 0, 0
+    for b in data:
+        last = ((b ^ key[index]) + last) & 0xFF
+        index = (index + 1) % len(key)
+        out.append(last)
+    return bytes(out)
+
+
+def decrypt(key: bytes, data: bytes) -> bytes:
+    out = bytearray()
+    index, last = 0, 0
+    for b in data:
+        out.append(((b - last) & 0xFF) ^ key[index])
+        index = (index + 1) % len(key)
+        last = b
+    return bytes(out)
+
+
+for _ in range(100):
+    session_key = rand_hex(40)
+    data = rand_hex(48)
+    key = bytes.fromhex(session_key)
+    row = [
+        session_key, # session key (40 bytes)
+        data, # headers, encrypted/decrypted as one stream (48 bytes)
+        encrypt(key, bytes.fromhex(data)).hex().upper(), # encrypted data
+        decrypt(key, bytes.fromhex(data)).hex().upper(), # decrypted data
+    ]
+    print(",".join(row))
@@ -0,0 +1,55 @@
+package srp
+
+import (
+	"crypto/subtle"
+	"errors"
+	"math/big"
+)
+
+var (
+	ErrInvalidPublicKey      = errors.New("srp: client public key is invalid")
+	ErrInvalidClientProof    = errors.New("srp: client proof does not match")
+	ErrInvalidReconnectProof = errors.New("srp: reconnect proof does not match")
+)
+
+// VerifyClientPublicKey returns ErrInvalidPublicKey if the client's public key is the wrong size
+// or is a multiple of the large prime. A client which sends such a key can log in without knowing
+// the password, so the server must check the key before computing the session key.
+func VerifyClientPublicKey(clientPublicKey []byte) error {
+	if len(clientPublicKey) != KeySize {
+		return ErrInvalidPublicKey
+	}
+	if big.NewInt(0).Mod(bytesToInt(clientPublicKey), n).Sign() == 0 {
+		return ErrInvalidPublicKey
+	}
+	return nil
+}
+
+// VerifyClientChallengeProof computes the client proof with [ClientChallengeProof] and compares it
+// with the proof sent by the client in constant time. If the proofs don't match,
+// VerifyClientChallengeProof returns ErrInvalidClientProof.
+func VerifyClientChallengeProof(
+	username string,
+	salt,
+	clientPublicKey,
+	serverPublicKey,
+	sessionKey,
+	clientProof []byte,
+) error {
+	expected := ClientChallengeProof(username, salt, clientPublicKey, serverPublicKey, sessionKey)
+	if subtle.ConstantTimeCompare(expected, clientProof) != 1 {
+		return ErrInvalidClientProof
+	}
+	return nil
+}
+
+// VerifyReconnectProof computes the reconnect proof with [ReconnectProof] and compares it with the
+// proof sent by the client in constant time. If the proofs don't match, VerifyReconnectProof
+// returns ErrInvalidReconnectProof.
+func VerifyReconnectProof(username string, clientData, serverData, sessionKey, clientProof []byte) error {
+	expected := ReconnectProof(username, clientData, serverData, sessionKey)
+	if subtle.ConstantTimeCompare(expected, clientProof) != 1 {
+		return ErrInvalidReconnectProof
+	}
+	return nil
+}
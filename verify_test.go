@@ -0,0 +1,62 @@
+package srp
+
+import (
+	"testing"
+
+	"github.com/kangaroux/go-wow-srp6/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyClientPublicKey(t *testing.T) {
+	rows := internal.MustLoadTestData("testdata/srp/calculate_client_proof.csv")
+
+	assert.NoError(t, VerifyClientPublicKey(internal.MustDecodeHex(rows[0][2])))
+	assert.ErrorIs(t, VerifyClientPublicKey(make([]byte, KeySize)), ErrInvalidPublicKey)
+	assert.ErrorIs(t, VerifyClientPublicKey(LargePrime()), ErrInvalidPublicKey)
+	assert.ErrorIs(t, VerifyClientPublicKey([]byte{1}), ErrInvalidPublicKey)
+}
+
+func TestVerifyClientChallengeProof(t *testing.T) {
+	rows := internal.MustLoadTestData("testdata/srp/calculate_client_proof.csv")
+
+	for _, row := range rows {
+		username := row[0]
+		salt := internal.MustDecodeHex(row[1])
+		clientPublic := internal.MustDecodeHex(row[2])
+		serverPublic := internal.MustDecodeHex(row[3])
+		sessionKey := internal.MustDecodeHex(row[4])
+		proof := internal.MustDecodeHex(row[5])
+
+		assert.NoError(t, VerifyClientChallengeProof(username, salt, clientPublic, serverPublic, sessionKey, proof))
+
+		proof[0] ^= 1
+		assert.ErrorIs(t,
+			VerifyClientChallengeProof(username, salt, clientPublic, serverPublic, sessionKey, proof),
+			ErrInvalidClientProof,
+		)
+		assert.ErrorIs(t,
+			VerifyClientChallengeProof(username, salt, clientPublic, serverPublic, sessionKey, nil),
+			ErrInvalidClientProof,
+		)
+	}
+}
+
+func TestVerifyReconnectProof(t *testing.T) {
+	rows := internal.MustLoadTestData("testdata/srp/calculate_reconnect_proof.csv")
+
+	for _, row := range rows {
+		username := row[0]
+		clientData := internal.MustDecodeHex(row[1])
+		serverData := internal.MustDecodeHex(row[2])
+		sessionKey := internal.MustDecodeHex(row[3])
+		proof := internal.MustDecodeHex(row[4])
+
+		assert.NoError(t, VerifyReconnectProof(username, clientData, serverData, sessionKey, proof))
+
+		proof[len(proof)-1] ^= 1
+		assert.ErrorIs(t,
+			VerifyReconnectProof(username, clientData, serverData, sessionKey, proof),
+			ErrInvalidReconnectProof,
+		)
+	}
+}
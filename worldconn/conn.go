@@ -0,0 +1,230 @@
+package worldconn
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kangaroux/go-wow-srp6/header"
+)
+
+var ErrAlreadyEnabled = errors.New("srp/worldconn: header encryption is already enabled")
+
+// Conn is a world connection. It reads and writes packets on a net.Conn, and encrypts the headers
+// once [Conn.Enable] has been called.
+//
+// WritePacket is safe to use concurrently. ReadPacket must only be called from one goroutine at a
+// time, since packets are read in order.
+type Conn struct {
+	conn net.Conn
+	r    *Reader
+	w    *Writer
+
+	// init is the Init method of the header
+	init    func(sessionKey []byte) error
+	enabled bool
+
+	read  deadline
+	write deadline
+}
+
+// NewServerConn returns a Conn for the server side of conn. It reads client packets and writes
+// server packets using h.
+func NewServerConn(conn net.Conn, h header.HeaderCipher) *Conn {
+	return newConn(conn, NewServerReader(conn, h), NewServerWriter(conn, h), h.Init)
+}
+
+// NewClientConn returns a Conn for the client side of conn. It reads server packets and writes
+// client packets using h.
+func NewClientConn(conn net.Conn, h *header.WrathClientHeader) *Conn {
+	return newConn(conn, NewClientReader(conn, h), NewClientWriter(conn, h), h.Init)
+}
+
+func newConn(conn net.Conn, r *Reader, w *Writer, init func([]byte) error) *Conn {
+	return &Conn{
+		conn:  conn,
+		r:     r,
+		w:     w,
+		init:  init,
+		read:  deadline{set: conn.SetReadDeadline},
+		write: deadline{set: conn.SetWriteDeadline},
+	}
+}
+
+// Enable turns on header encryption using sessionKey. Packets written before Enable are sent in
+// plain text, and every packet written after it is encrypted. A packet being written while Enable
+// is called is finished first. The next header read after Enable is decrypted.
+//
+// The server should call Enable once it has verified CMSG_AUTH_SESSION, so SMSG_AUTH_RESPONSE is
+// the first encrypted packet. The client should call Enable once it has sent CMSG_AUTH_SESSION,
+// and before reading the response. Enable can only be called once, and returns ErrAlreadyEnabled
+// after that.
+func (c *Conn) Enable(sessionKey []byte) error {
+	c.w.mu.Lock()
+	defer c.w.mu.Unlock()
+
+	if c.enabled {
+		return ErrAlreadyEnabled
+	}
+	if err := c.init(sessionKey); err != nil {
+		return err
+	}
+	c.enabled = true
+	return nil
+}
+
+// ReadPacket reads the next packet. The body is only valid until the next call to ReadPacket.
+// ReadPacket returns ctx.Err() if ctx is done before the packet has been read, after which the
+// connection should be closed.
+func (c *Conn) ReadPacket(ctx context.Context) (Packet, error) {
+	var p Packet
+	err := withContext(ctx, &c.read, func() error {
+		var err error
+		p, err = c.r.ReadPacket()
+		return err
+	})
+	return p, err
+}
+
+// WritePacket writes p. Concurrent calls are written one at a time, in order. WritePacket returns
+// ctx.Err() if ctx is done before the packet has been written. If part of the packet was already
+// written, the connection should be closed.
+func (c *Conn) WritePacket(ctx context.Context, p Packet) error {
+	// Hold the lock for the whole call, so each write only uses its own context
+	c.w.mu.Lock()
+	defer c.w.mu.Unlock()
+
+	return withContext(ctx, &c.write, func() error {
+		return c.w.writePacket(p)
+	})
+}
+
+// SetDeadline sets the read and write deadlines, the same as [net.Conn.SetDeadline].
+func (c *Conn) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(t)
+}
+
+// SetReadDeadline sets the deadline for ReadPacket. If the context passed to ReadPacket has an
+// earlier deadline, that is used instead.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.read.setDeadline(t)
+}
+
+// SetWriteDeadline sets the deadline for WritePacket. If the context passed to WritePacket has an
+// earlier deadline, that is used instead.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.write.setDeadline(t)
+}
+
+// RemoteAddr returns the remote address of the connection.
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// Close closes the connection.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// deadline combines the deadline set on a Conn with the context of the call in progress, for one
+// direction of the connection.
+type deadline struct {
+	mu sync.Mutex
+
+	// set is SetReadDeadline or SetWriteDeadline of the net.Conn
+	set func(time.Time) error
+
+	// user is the deadline set with SetReadDeadline or SetWriteDeadline
+	user time.Time
+
+	// ctx is the deadline of the context of the call in progress, if any
+	ctx time.Time
+
+	canceled bool
+}
+
+func (d *deadline) setDeadline(t time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.user = t
+	return d.apply()
+}
+
+// apply sets the earliest deadline on the connection. It must be called with mu held.
+func (d *deadline) apply() error {
+	t := d.user
+	if d.canceled {
+		// Any time in the past interrupts blocked calls
+		t = time.Unix(1, 0)
+	} else if !d.ctx.IsZero() && (t.IsZero() || d.ctx.Before(t)) {
+		t = d.ctx
+	}
+	return d.set(t)
+}
+
+// withContext runs fn, interrupting it once ctx is done.
+func withContext(ctx context.Context, d *deadline, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// The context can't be canceled and has no deadline
+	if ctx.Done() == nil {
+		return fn()
+	}
+
+	d.mu.Lock()
+	d.ctx, _ = ctx.Deadline()
+	err := d.apply()
+	d.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		select {
+		case <-ctx.Done():
+			d.mu.Lock()
+			d.canceled = true
+			d.apply()
+			d.mu.Unlock()
+		case <-stop:
+		}
+	}()
+
+	err = fn()
+	close(stop)
+	<-done
+
+	d.mu.Lock()
+	d.ctx = time.Time{}
+	d.canceled = false
+	d.apply()
+	d.mu.Unlock()
+
+	if err == nil {
+		return nil
+	}
+
+	// The connection can reach the context's deadline before ctx.Err is set
+	deadline, ok := ctx.Deadline()
+	if ok && errors.Is(err, os.ErrDeadlineExceeded) && !time.Now().Before(deadline) {
+		<-ctx.Done()
+	}
+
+	// Only blame ctx if it interrupted fn
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
@@ -0,0 +1,153 @@
+package worldconn
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/kangaroux/go-wow-srp6/header"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	smsgAuthChallenge = 0x1EC
+	cmsgAuthSession   = 0x1ED
+	smsgAuthResponse  = 0x1EE
+)
+
+func testConns() (server *Conn, client *Conn) {
+	serverConn, clientConn := net.Pipe()
+	server = NewServerConn(serverConn, &header.WrathHeader{})
+	client = NewClientConn(clientConn, &header.WrathClientHeader{})
+	return server, client
+}
+
+func TestConnEnable(t *testing.T) {
+	ctx := context.Background()
+	server, client := testConns()
+	defer server.Close()
+	defer client.Close()
+
+	// The server sends the challenge in plain text
+	go server.WritePacket(ctx, Packet{Opcode: smsgAuthChallenge, Body: []byte{1, 2, 3}})
+	p, err := client.ReadPacket(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, Packet{Opcode: smsgAuthChallenge, Body: []byte{1, 2, 3}}, p)
+
+	// The client sends the auth session in plain text, then turns on encryption
+	go func() {
+		client.WritePacket(ctx, Packet{Opcode: cmsgAuthSession, Body: []byte{4, 5}})
+		client.Enable(testSessionKey)
+		client.WritePacket(ctx, Packet{Opcode: 0x1DC, Body: []byte{}})
+	}()
+	p, err = server.ReadPacket(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, Packet{Opcode: cmsgAuthSession, Body: []byte{4, 5}}, p)
+
+	// The auth response is the first encrypted server packet
+	assert.NoError(t, server.Enable(testSessionKey))
+	assert.ErrorIs(t, server.Enable(testSessionKey), ErrAlreadyEnabled)
+	go server.WritePacket(ctx, Packet{Opcode: smsgAuthResponse, Body: []byte{0x0C}})
+
+	p, err = server.ReadPacket(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, Packet{Opcode: 0x1DC, Body: []byte{}}, p)
+
+	p, err = client.ReadPacket(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, Packet{Opcode: smsgAuthResponse, Body: []byte{0x0C}}, p)
+}
+
+func TestConnContext(t *testing.T) {
+	server, client := testConns()
+	defer server.Close()
+	defer client.Close()
+
+	// Canceled while blocked
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	_, err := server.ReadPacket(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	assert.ErrorIs(t, server.WritePacket(ctx, Packet{Opcode: smsgAuthChallenge}), context.Canceled)
+
+	// Already canceled
+	assert.ErrorIs(t, server.WritePacket(ctx, Packet{Opcode: smsgAuthChallenge}), context.Canceled)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = client.ReadPacket(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+// lateContext is a context whose deadline has passed before it's done, like a context from
+// context.WithDeadline whose timer hasn't fired yet.
+type lateContext struct {
+	context.Context
+	deadline time.Time
+	done     chan struct{}
+}
+
+// newLateContext returns a context with a deadline of d, which is only done after late.
+func newLateContext(d, late time.Duration) *lateContext {
+	ctx := &lateContext{
+		Context:  context.Background(),
+		deadline: time.Now().Add(d),
+		done:     make(chan struct{}),
+	}
+	time.AfterFunc(late, func() { close(ctx.done) })
+	return ctx
+}
+
+func (c *lateContext) Deadline() (time.Time, bool) { return c.deadline, true }
+func (c *lateContext) Done() <-chan struct{}       { return c.done }
+
+func (c *lateContext) Err() error {
+	select {
+	case <-c.done:
+		return context.DeadlineExceeded
+	default:
+		return nil
+	}
+}
+
+func TestConnContextDeadline(t *testing.T) {
+	server, client := testConns()
+	defer server.Close()
+	defer client.Close()
+
+	// The connection times out before the context is done
+	_, err := server.ReadPacket(newLateContext(10*time.Millisecond, 50*time.Millisecond))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	err = server.WritePacket(newLateContext(10*time.Millisecond, 50*time.Millisecond), Packet{Opcode: smsgAuthChallenge})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestConnDeadline(t *testing.T) {
+	server, client := testConns()
+	defer server.Close()
+	defer client.Close()
+
+	// The connection deadline is used if it's earlier than the context deadline
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+
+	assert.NoError(t, server.SetReadDeadline(time.Now().Add(20*time.Millisecond)))
+	_, err := server.ReadPacket(ctx)
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+
+	// The connection deadline is kept after a call with a context deadline
+	_, err = server.ReadPacket(context.Background())
+	assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+
+	// The deadline can be cleared
+	assert.NoError(t, server.SetDeadline(time.Time{}))
+	go client.WritePacket(ctx, Packet{Opcode: cmsgAuthSession})
+	_, err = server.ReadPacket(ctx)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,153 @@
+// Package worldconn reads and writes world packets. A packet is a header, encrypted once the
+// client has authenticated, followed by the body.
+//
+// The server reads client packets and writes server packets using a [header.HeaderCipher], and the
+// client does the opposite using a [header.WrathClientHeader]. Buffers are reused between packets,
+// so reading and writing a packet doesn't allocate once the buffers are large enough.
+//
+// [Conn] puts both together on a net.Conn, with deadlines, contexts, and [Conn.Enable] to turn on
+// encryption between two packets.
+//
+// The header ciphers are stream ciphers, so the reader and writer must see every packet. After any
+// error, other than io.EOF before a packet, the stream is in an unknown state and the connection
+// should be closed.
+package worldconn
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/kangaroux/go-wow-srp6/header"
+)
+
+// Server opcodes are 2 bytes.
+const maxServerOpcode = 0xFFFF
+
+var ErrInvalidOpcode = errors.New("srp/worldconn: opcode is too large for a server header")
+
+// Packet is a world packet. The body does not include the header.
+type Packet struct {
+	Opcode uint32
+	Body   []byte
+}
+
+// Reader reads packets from a stream.
+type Reader struct {
+	r *bufio.Reader
+
+	// readHeader reads and decrypts the next header, and returns the opcode and the body size
+	readHeader func() (opcode uint32, size uint32, err error)
+
+	header [header.ClientHeaderSize]byte
+	body   []byte
+}
+
+// NewServerReader returns a Reader for the server side, which reads client packets from r. The
+// headers are decrypted with h once h.Init has been called.
+func NewServerReader(r io.Reader, h header.HeaderCipher) *Reader {
+	rd := &Reader{r: bufio.NewReader(r)}
+	rd.readHeader = func() (uint32, uint32, error) {
+		data := rd.header[:h.ClientHeaderSize()]
+		if _, err := io.ReadFull(rd.r, data); err != nil {
+			return 0, 0, err
+		}
+		return h.DecodeClientHeader(data)
+	}
+	return rd
+}
+
+// NewClientReader returns a Reader for the client side, which reads server packets from r. The
+// headers are decrypted with h once h.Init has been called.
+func NewClientReader(r io.Reader, h *header.WrathClientHeader) *Reader {
+	rd := &Reader{r: bufio.NewReader(r)}
+	rd.readHeader = func() (uint32, uint32, error) {
+		opcode, size, err := h.ReadServerHeader(rd.r)
+		return uint32(opcode), size, err
+	}
+	return rd
+}
+
+// ReadPacket reads the next packet. The body is only valid until the next call to ReadPacket, and
+// must be copied if it's needed for longer. The body is never nil, even if it's empty. If the
+// stream ends part way through a packet, ReadPacket returns io.ErrUnexpectedEOF.
+func (rd *Reader) ReadPacket() (Packet, error) {
+	opcode, size, err := rd.readHeader()
+	if err != nil {
+		return Packet{}, err
+	}
+
+	// Allocate even for an empty body, so it's never nil
+	if rd.body == nil || uint32(cap(rd.body)) < size {
+		rd.body = make([]byte, size)
+	}
+	body := rd.body[:size]
+
+	if _, err := io.ReadFull(rd.r, body); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return Packet{}, err
+	}
+
+	return Packet{Opcode: opcode, Body: body}, nil
+}
+
+// Writer writes packets to a stream. It is safe to use concurrently.
+//
+// Each header is encrypted and written under the same lock, so packets are written in the order
+// they were encrypted. Calling Encode and Write separately from several goroutines can put the
+// headers on the wire in a different order than the keystream, and the other side silently
+// desyncs.
+type Writer struct {
+	mu sync.Mutex
+	w  io.Writer
+
+	// encode appends the encrypted header for a packet to dst
+	encode func(dst []byte, opcode uint32, size uint32) ([]byte, error)
+
+	buf []byte
+}
+
+// NewServerWriter returns a Writer for the server side, which writes server packets to w. The
+// headers are encrypted with h once h.Init has been called.
+func NewServerWriter(w io.Writer, h header.HeaderCipher) *Writer {
+	return &Writer{
+		w: w,
+		encode: func(dst []byte, opcode uint32, size uint32) ([]byte, error) {
+			if opcode > maxServerOpcode {
+				return nil, ErrInvalidOpcode
+			}
+			return h.AppendEncode(dst, uint16(opcode), size)
+		},
+	}
+}
+
+// NewClientWriter returns a Writer for the client side, which writes client packets to w. The
+// headers are encrypted with h once h.Init has been called.
+func NewClientWriter(w io.Writer, h *header.WrathClientHeader) *Writer {
+	return &Writer{w: w, encode: h.AppendEncode}
+}
+
+// WritePacket writes the header and body of p with a single call to Write, so a packet is never
+// split between writes. Concurrent calls are written one at a time, in the order their headers
+// were encrypted.
+func (w *Writer) WritePacket(p Packet) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.writePacket(p)
+}
+
+// writePacket writes p. It must be called with mu held.
+func (w *Writer) writePacket(p Packet) error {
+	buf, err := w.encode(w.buf[:0], p.Opcode, uint32(len(p.Body)))
+	if err != nil {
+		return err
+	}
+	w.buf = append(buf, p.Body...)
+
+	_, err = w.w.Write(w.buf)
+	return err
+}
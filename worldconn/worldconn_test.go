@@ -0,0 +1,235 @@
+package worldconn
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kangaroux/go-wow-srp6/header"
+	"github.com/kangaroux/go-wow-srp6/internal"
+	"github.com/stretchr/testify/assert"
+)
+
+var testSessionKey = internal.MustDecodeHex("403FCE7B2B1FCDAE43F118B6C7E517D5A1498088180936A3E45B9888978B7675ECBAA7DB4CA4E8DE")
+
+func testPackets() []Packet {
+	return []Packet{
+		{Opcode: 0x1EE, Body: []byte{1, 2, 3}},
+		{Opcode: 0x1ED, Body: []byte{}},
+		{Opcode: 0x3B, Body: bytes.Repeat([]byte{0xAB}, 100)},
+		{Opcode: 0x1EE, Body: []byte{4, 5}},
+	}
+}
+
+// writePackets writes packets to w in the background. net.Pipe is synchronous, so writes block
+// until the other end reads them.
+func writePackets(w *Writer, packets []Packet) <-chan error {
+	done := make(chan error, 1)
+	go func() {
+		for _, p := range packets {
+			if err := w.WritePacket(p); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	return done
+}
+
+func TestConn(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+
+	serverHeader := &header.WrathHeader{}
+	clientHeader := &header.WrathClientHeader{}
+	assert.NoError(t, serverHeader.Init(testSessionKey))
+	assert.NoError(t, clientHeader.Init(testSessionKey))
+
+	serverReader := NewServerReader(serverConn, serverHeader)
+	serverWriter := NewServerWriter(serverConn, serverHeader)
+	clientReader := NewClientReader(clientConn, clientHeader)
+	clientWriter := NewClientWriter(clientConn, clientHeader)
+
+	// Client -> server
+	packets := testPackets()
+	done := writePackets(clientWriter, packets)
+	for _, expected := range packets {
+		p, err := serverReader.ReadPacket()
+		assert.NoError(t, err)
+		assert.Equal(t, expected.Opcode, p.Opcode)
+		assert.Equal(t, expected.Body, p.Body)
+	}
+	assert.NoError(t, <-done)
+
+	// Server -> client, including a packet with a large header
+	packets = append(packets, Packet{Opcode: 0xA9, Body: make([]byte, 0x10000)})
+	done = writePackets(serverWriter, packets)
+	for _, expected := range packets {
+		p, err := clientReader.ReadPacket()
+		assert.NoError(t, err)
+		assert.Equal(t, expected.Opcode, p.Opcode)
+		assert.Equal(t, expected.Body, p.Body)
+	}
+	assert.NoError(t, <-done)
+}
+
+func TestWriterInvalidOpcode(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewServerWriter(&buf, &header.WrathHeader{})
+
+	assert.ErrorIs(t, w.WritePacket(Packet{Opcode: 0x10000}), ErrInvalidOpcode)
+	assert.Zero(t, buf.Len())
+
+	assert.NoError(t, w.WritePacket(Packet{Opcode: 0xFFFF}))
+	assert.Equal(t, []byte{0x00, 0x02, 0xFF, 0xFF}, buf.Bytes())
+}
+
+func TestReaderUnexpectedEOF(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewClientWriter(&buf, &header.WrathClientHeader{})
+	assert.NoError(t, w.WritePacket(Packet{Opcode: 0x1ED, Body: []byte{1, 2, 3}}))
+
+	// The body is cut short
+	r := NewServerReader(bytes.NewReader(buf.Bytes()[:buf.Len()-1]), &header.WrathHeader{})
+	_, err := r.ReadPacket()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	// The stream ends between packets
+	r = NewServerReader(bytes.NewReader(buf.Bytes()), &header.WrathHeader{})
+	_, err = r.ReadPacket()
+	assert.NoError(t, err)
+	_, err = r.ReadPacket()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestReaderHeaderError(t *testing.T) {
+	r := NewServerReader(bytes.NewReader([]byte{0x28, 0x05, 0, 0, 0, 0}), &header.WrathHeader{})
+	_, err := r.ReadPacket()
+	assert.ErrorIs(t, err, header.ErrHeaderSizeTooLarge)
+}
+
+func TestReaderEmptyBody(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewClientWriter(&buf, &header.WrathClientHeader{})
+	assert.NoError(t, w.WritePacket(Packet{Opcode: 0x1ED}))
+
+	// The body is empty but not nil, even as the first packet
+	r := NewServerReader(bytes.NewReader(buf.Bytes()), &header.WrathHeader{})
+	p, err := r.ReadPacket()
+	assert.NoError(t, err)
+	assert.Equal(t, Packet{Opcode: 0x1ED, Body: []byte{}}, p)
+}
+
+func TestReaderReusesBuffer(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewClientWriter(&buf, &header.WrathClientHeader{})
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, w.WritePacket(Packet{Opcode: 0x1ED, Body: []byte{1, 2, 3}}))
+	}
+
+	stream := bytes.NewReader(buf.Bytes())
+	r := NewServerReader(stream, &header.WrathHeader{})
+
+	// Read once so the buffers are allocated
+	_, err := r.ReadPacket()
+	assert.NoError(t, err)
+
+	allocs := testing.AllocsPerRun(50, func() {
+		if _, err := r.ReadPacket(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assert.Zero(t, allocs)
+}
+
+func TestWriterConcurrent(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	defer serverConn.Close()
+	defer clientConn.Close()
+
+	serverHeader := &header.WrathHeader{}
+	clientHeader := &header.WrathClientHeader{}
+	assert.NoError(t, serverHeader.Init(testSessionKey))
+	assert.NoError(t, clientHeader.Init(testSessionKey))
+
+	w := NewServerWriter(serverConn, serverHeader)
+	r := NewClientReader(clientConn, clientHeader)
+
+	// A desync usually looks like a huge packet, so fail instead of waiting for it forever
+	clientConn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	const writers = 8
+	const packetsPerWriter = 200
+
+	// Each writer sends its own opcode, with a body that counts up. If the headers were written
+	// out of order, the client would fail to decrypt them.
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(opcode uint32) {
+			defer wg.Done()
+			for n := 0; n < packetsPerWriter; n++ {
+				body := bytes.Repeat([]byte{byte(n)}, int(opcode)*100+n)
+				if err := w.WritePacket(Packet{Opcode: opcode, Body: body}); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(uint32(i))
+	}
+
+	next := make([]int, writers)
+	for i := 0; i < writers*packetsPerWriter; i++ {
+		p, err := r.ReadPacket()
+		if !assert.NoError(t, err) {
+			break
+		}
+		if !assert.Less(t, p.Opcode, uint32(writers)) {
+			break
+		}
+
+		// Packets from the same writer arrive in the order they were sent
+		n := next[p.Opcode]
+		next[p.Opcode]++
+		assert.Equal(t, bytes.Repeat([]byte{byte(n)}, int(p.Opcode)*100+n), p.Body)
+	}
+
+	// Unblock the writers if reading failed
+	clientConn.Close()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+}
+
+func TestWriterReusesBuffer(t *testing.T) {
+	serverHeader := &header.WrathHeader{}
+	clientHeader := &header.WrathClientHeader{}
+	assert.NoError(t, serverHeader.Init(testSessionKey))
+	assert.NoError(t, clientHeader.Init(testSessionKey))
+
+	server := NewServerWriter(io.Discard, serverHeader)
+	client := NewClientWriter(io.Discard, clientHeader)
+	p := Packet{Opcode: 0x1EE, Body: make([]byte, 100)}
+
+	// Write once so the buffers are allocated
+	assert.NoError(t, server.WritePacket(p))
+	assert.NoError(t, client.WritePacket(p))
+
+	allocs := testing.AllocsPerRun(50, func() {
+		if err := server.WritePacket(p); err != nil {
+			t.Fatal(err)
+		}
+		if err := client.WritePacket(p); err != nil {
+			t.Fatal(err)
+		}
+	})
+	assert.Zero(t, allocs)
+}